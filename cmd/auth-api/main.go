@@ -25,6 +25,8 @@ func main() {
 
 	application := app.New(log, *config)
 	go application.GRPCServer.Run()
+	go application.HTTPServer.Run()
+	go application.Purger.Run()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -32,7 +34,10 @@ func main() {
 	stoped := <-stop
 
 	log.Info("stoping application", slog.String("signal", stoped.String()))
+	application.Purger.Stop()
+	application.HTTPServer.Stop()
 	application.GRPCServer.Stop()
+	application.Auth.Wait()
 	log.Info("application stoped")
 }
 
@@ -2,14 +2,41 @@ package app
 
 import (
 	grpcapp "auth-api/internal/app/grpc"
+	httpapp "auth-api/internal/app/http"
+	purgerapp "auth-api/internal/app/purger"
 	"auth-api/internal/config"
+	"auth-api/internal/grpc/ratelimit"
+	"auth-api/internal/lib/breach"
+	"auth-api/internal/lib/clientinfo"
+	"auth-api/internal/lib/displayname"
+	"auth-api/internal/lib/emailaddr"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/passhash"
+	"auth-api/internal/lib/password"
+	"auth-api/internal/lib/secretbox"
+	"auth-api/internal/lib/throttle"
+	"auth-api/internal/lib/webauthn"
 	"auth-api/internal/services/auth"
+	"auth-api/internal/storage/memory"
 	"auth-api/internal/storage/postgresql"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log/slog"
 )
 
+const (
+	defaultKeyID = "default"
+	envLocal     = "local"
+)
+
 type App struct {
 	GRPCServer *grpcapp.App
+	HTTPServer *httpapp.App
+	Purger     *purgerapp.App
+	Auth       *auth.Auth
 }
 
 func New(log *slog.Logger, config config.Config) *App {
@@ -17,7 +44,246 @@ func New(log *slog.Logger, config config.Config) *App {
 	if err != nil {
 		panic(err)
 	}
-	authService := auth.New(log, storage, storage, config.AccessTTL, config.AccessSecret, config.RefreshTTL, config.RefreshSecret)
-	grpcApp := grpcapp.New(log, authService, config.GRPCConfig.Port)
-	return &App{GRPCServer: grpcApp}
+	accessKeys, err := newKeyRing(config.AccessKeys, config.AccessSecret)
+	if err != nil {
+		panic(fmt.Errorf("access keys: %w", err))
+	}
+	refreshKeys, err := newKeyRing(config.RefreshKeys, config.RefreshSecret)
+	if err != nil {
+		panic(fmt.Errorf("refresh keys: %w", err))
+	}
+	revocations := memory.NewRevocations(storage, config.AccessTTL)
+	if config.Revocation.Store == "memory" {
+		revocations = memory.NewRevocations(nil, config.AccessTTL)
+	}
+	mail, err := newMailer(log, config.Env, config.Mailer)
+	if err != nil {
+		panic(err)
+	}
+	var attempts throttle.Store = storage
+	if config.LoginThrottle.Store == "memory" {
+		attempts = memory.NewLoginAttempts()
+	}
+	breached, err := newBreachChecker(config.BreachCheck)
+	if err != nil {
+		panic(fmt.Errorf("breach check: %w", err))
+	}
+	hasher, err := newHasher(config.PasswordHash)
+	if err != nil {
+		panic(fmt.Errorf("password hash: %w", err))
+	}
+	policy := &password.Policy{
+		MinLength:      config.PasswordRules.MinLength,
+		MaxLength:      config.PasswordRules.MaxLength,
+		RequireLower:   config.PasswordRules.RequireLower,
+		RequireUpper:   config.PasswordRules.RequireUpper,
+		RequireDigit:   config.PasswordRules.RequireDigit,
+		RequireSymbol:  config.PasswordRules.RequireSymbol,
+		RejectPersonal: config.PasswordRules.RejectPersonal,
+		RejectCommon:   config.PasswordRules.RejectCommon,
+		MinScore:       config.PasswordRules.MinScore,
+	}
+	if hasher.Algorithm() == passhash.AlgorithmBcrypt && config.PasswordHash.Pepper.Current == 0 {
+		// bcrypt only hashes the first 72 bytes. A peppered password is a
+		// fixed size HMAC and fits.
+		policy.MaxBytes = 72
+	}
+	// Without a key TOTP stays disabled.
+	var box *secretbox.Box
+	if config.MFA.EncryptionKey != "" {
+		box, err = secretbox.New(config.MFA.EncryptionKey)
+		if err != nil {
+			panic(fmt.Errorf("mfa encryption key: %w", err))
+		}
+	}
+	authService := auth.New(log, storage, storage, revocations, mail, auth.Config{
+		AccessTTL:            config.AccessTTL,
+		AccessKeys:           accessKeys,
+		RefreshTTL:           config.RefreshTTL,
+		RefreshKeys:          refreshKeys,
+		Issuer:               config.Issuer,
+		Audiences:            config.Audiences,
+		VerificationTTL:      config.Verification.TTL,
+		VerificationURL:      config.Verification.URL,
+		EmailChangeURL:       config.Verification.ChangeURL,
+		RequireVerifiedEmail: config.Verification.Required,
+		PasswordResetTTL:     config.PasswordReset.TTL,
+		PasswordResetURL:     config.PasswordReset.URL,
+		DeletionRetention:    config.Deletion.Retention,
+		SecretBox:            box,
+		TOTPIssuer:           config.MFA.Issuer,
+		MFAChallengeTTL:      config.MFA.ChallengeTTL,
+		WebAuthn: webauthn.New(webauthn.Config{
+			RPID:             config.WebAuthn.RPID,
+			RPName:           config.WebAuthn.RPName,
+			Origins:          config.WebAuthn.Origins,
+			UserVerification: config.WebAuthn.UserVerification,
+		}),
+		PasskeyChallengeTTL:  config.WebAuthn.ChallengeTTL,
+		AccountThrottle:      throttle.New("account", attempts, throttlePolicy(config.LoginThrottle.Account)),
+		IPThrottle:           throttle.New("ip", attempts, throttlePolicy(config.LoginThrottle.IP)),
+		LockoutThreshold:     config.Lockout.Threshold,
+		LockoutDuration:      config.Lockout.Duration,
+		AdminKey:             config.Admin.APIKey,
+		IntrospectionClients: config.Introspection.Clients,
+		PasswordPolicy:       policy,
+		BreachChecker:        breached,
+		BreachFailOpen:       config.BreachCheck.FailOpen,
+		PasswordHasher:       hasher,
+		Emails:               emailaddr.New(config.Email.BlockDisposable, config.Email.BlockedDomains),
+		Names:                displayname.New(config.DisplayName.MinLength, config.DisplayName.MaxLength, config.DisplayName.Reserved),
+	})
+	limiter, err := newRateLimiter(config, accessKeys)
+	if err != nil {
+		panic(fmt.Errorf("rate limit: %w", err))
+	}
+	proxies, err := clientinfo.ParseProxies(config.GRPCConfig.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	grpcApp := grpcapp.New(log, authService, config.GRPCConfig.Port, proxies, limiter)
+	httpApp := httpapp.New(log, authService, config.HTTPConfig.Port, config.HTTPConfig.Timeout)
+	purgerApp := purgerapp.New(log, config.Deletion.PurgeInterval,
+		purgerapp.Job{Name: "deleted accounts", Run: authService.PurgeDeletedAccounts},
+		purgerapp.Job{Name: "webauthn challenges", Run: authService.PurgeExpiredChallenges},
+		purgerapp.Job{Name: "login failures", Run: authService.PurgeLoginFailures},
+		purgerapp.Job{Name: "revoked access tokens", Run: storage.PurgeRevokedAccessTokens},
+	)
+	return &App{GRPCServer: grpcApp, HTTPServer: httpApp, Purger: purgerApp, Auth: authService}
+}
+
+// newRateLimiter builds the gRPC rate limiter, nil when it is disabled.
+// Subjects are taken from access tokens with a valid signature only, so
+// forged tokens cannot pick someone else's bucket.
+func newRateLimiter(cfg config.Config, accessKeys *jwt.KeyRing) (*ratelimit.Limiter, error) {
+	if !cfg.RateLimit.Enabled {
+		return nil, nil
+	}
+
+	budgets := make(map[string]ratelimit.Budget, len(cfg.RateLimit.Methods))
+	for method, budget := range cfg.RateLimit.Methods {
+		b, err := rateBudget(budget)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		budgets[method] = b
+	}
+
+	var def *ratelimit.Budget
+	if cfg.RateLimit.Default.Rate != 0 {
+		b, err := rateBudget(cfg.RateLimit.Default)
+		if err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+		def = &b
+	}
+
+	subject := func(ctx context.Context, token string) (string, bool) {
+		claims, err := jwt.ParseAccessToken(token, accessKeys, cfg.Issuer, cfg.Audiences)
+		if err != nil {
+			return "", false
+		}
+		return claims.Subject, true
+	}
+
+	return ratelimit.New(budgets, def, subject, cfg.RateLimit.MaxBuckets), nil
+}
+
+func rateBudget(cfg config.RateBudget) (ratelimit.Budget, error) {
+	if cfg.Rate <= 0 || cfg.Burst < 1 {
+		return ratelimit.Budget{}, errors.New("rate and burst must be positive")
+	}
+	switch cfg.Key {
+	case "":
+		cfg.Key = ratelimit.KeyIP
+	case ratelimit.KeyIP, ratelimit.KeySubject:
+	default:
+		return ratelimit.Budget{}, fmt.Errorf("unknown key %q", cfg.Key)
+	}
+	return ratelimit.Budget{Rate: cfg.Rate, Burst: cfg.Burst, Key: cfg.Key}, nil
+}
+
+func throttlePolicy(cfg config.ThrottlePolicy) throttle.Policy {
+	return throttle.Policy{
+		FreeAttempts: cfg.FreeAttempts,
+		BaseDelay:    cfg.BaseDelay,
+		MaxDelay:     cfg.MaxDelay,
+		Window:       cfg.Window,
+	}
+}
+
+func newKeyRing(ring config.KeyRing, secret string) (*jwt.KeyRing, error) {
+	if len(ring.Keys) == 0 {
+		key, err := jwt.NewHMACKey(defaultKeyID, secret)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewKeyRing(defaultKeyID, key)
+	}
+
+	keys := make([]*jwt.Key, 0, len(ring.Keys))
+	for _, cfg := range ring.Keys {
+		key, err := jwt.LoadKey(cfg.ID, cfg.Algorithm, cfg.Secret, cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+		if !cfg.RetiresAt.IsZero() {
+			key.RetireAt(cfg.RetiresAt)
+		}
+		keys = append(keys, key)
+	}
+	return jwt.NewKeyRing(ring.Active, keys...)
+}
+
+// newMailer refuses the log driver outside env local, it would put working
+// verification and reset links into shared logs.
+func newMailer(log *slog.Logger, env string, cfg config.Mailer) (auth.Mailer, error) {
+	switch cfg.Driver {
+	case mailer.DriverLog:
+		if env != envLocal {
+			return nil, fmt.Errorf("mailer driver %q is only allowed in env %q", cfg.Driver, envLocal)
+		}
+		return mailer.NewLogMailer(log), nil
+	case mailer.DriverFile:
+		return mailer.NewFileMailer(cfg.Dir, cfg.From)
+	case mailer.DriverSMTP:
+		return mailer.NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From, cfg.Timeout), nil
+	}
+	return nil, fmt.Errorf("unknown mailer driver %q", cfg.Driver)
+}
+
+// newBreachChecker returns nil when the check is disabled.
+func newBreachChecker(cfg config.BreachCheck) (auth.BreachChecker, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case breach.DriverHTTP:
+		return breach.NewRangeClient(cfg.URL, cfg.Timeout), nil
+	case breach.DriverFile:
+		return breach.NewFileChecker(cfg.Path)
+	}
+	return nil, fmt.Errorf("unknown driver %q", cfg.Driver)
+}
+
+func newHasher(cfg config.PasswordHash) (*passhash.Hasher, error) {
+	keys := make(map[int][]byte, len(cfg.Pepper.Keys))
+	for version, encoded := range cfg.Pepper.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("pepper version %d: decode key: %w", version, err)
+		}
+		keys[version] = key
+	}
+	pepper, err := passhash.NewPepper(cfg.Pepper.Current, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return passhash.New(cfg.Algorithm, passhash.Argon2Params{
+		Memory:      cfg.Argon2.Memory,
+		Iterations:  cfg.Argon2.Iterations,
+		Parallelism: cfg.Argon2.Parallelism,
+		SaltLength:  cfg.Argon2.SaltLength,
+		KeyLength:   cfg.Argon2.KeyLength,
+	}, cfg.BcryptCost, pepper)
 }
@@ -2,6 +2,8 @@ package grpcapp
 
 import (
 	authgrpc "auth-api/internal/grpc/auth"
+	"auth-api/internal/grpc/ratelimit"
+	"auth-api/internal/lib/clientinfo"
 	"fmt"
 	"log/slog"
 	"net"
@@ -16,8 +18,12 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
-	gRPCServer := grpc.NewServer()
+func New(log *slog.Logger, authService authgrpc.Auth, port int, proxies clientinfo.Proxies, limiter *ratelimit.Limiter) *App {
+	interceptors := []grpc.UnaryServerInterceptor{clientinfo.UnaryServerInterceptor(proxies)}
+	if limiter != nil {
+		interceptors = append(interceptors, limiter.UnaryServerInterceptor())
+	}
+	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
 
 	authgrpc.Register(gRPCServer, authService)
 
@@ -0,0 +1,74 @@
+package httpapp
+
+import (
+	"auth-api/internal/http/wellknown"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+	timeout    time.Duration
+}
+
+func New(log *slog.Logger, keys wellknown.KeyProvider, port int, timeout time.Duration) *App {
+	mux := http.NewServeMux()
+
+	wellknown.Register(mux, keys)
+
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: timeout,
+			WriteTimeout:      timeout,
+		},
+		port:    port,
+		timeout: timeout,
+	}
+}
+
+func (app *App) Run() {
+	if err := app.run(); err != nil {
+		panic(err)
+	}
+}
+
+func (app *App) run() error {
+	const op = "httpApp.Run"
+
+	log := app.log.With(slog.String("op", op), slog.Int("port", app.port))
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", app.port))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("HTTP is running", slog.String("addr", listener.Addr().String()))
+
+	if err := app.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (app *App) Stop() {
+	const op = "http.App"
+
+	app.log.With(slog.String("op", op)).Info("stoping HTTP server", slog.Int("port", app.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.timeout)
+	defer cancel()
+
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.log.With(slog.String("op", op)).Error("failed to stop HTTP server", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,81 @@
+package purgerapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a cleanup run on every tick, it reports how many rows it removed.
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) (int64, error)
+}
+
+// App periodically removes soft deleted accounts whose retention has passed
+// and rows that expired unused.
+type App struct {
+	log      *slog.Logger
+	jobs     []Job
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(log *slog.Logger, interval time.Duration, jobs ...Job) *App {
+	return &App{
+		log:      log,
+		jobs:     jobs,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (app *App) Run() {
+	const op = "purgerApp.Run"
+
+	log := app.log.With(slog.String("op", op))
+	log.Info("purger is running", slog.Duration("interval", app.interval))
+
+	defer close(app.done)
+
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range app.jobs {
+			app.purge(log, job)
+		}
+
+		select {
+		case <-app.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *App) Stop() {
+	const op = "purger.App"
+
+	app.log.With(slog.String("op", op)).Info("stoping purger")
+
+	close(app.stop)
+	<-app.done
+}
+
+func (app *App) purge(log *slog.Logger, job Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), app.interval)
+	defer cancel()
+
+	log = log.With(slog.String("job", job.Name))
+	purged, err := job.Run(ctx)
+	if err != nil {
+		log.Error("failed to purge", slog.String("error", err.Error()))
+		return
+	}
+	if purged > 0 {
+		log.Info("purged", slog.Int64("count", purged))
+	}
+}
@@ -11,16 +11,253 @@ import (
 type Config struct {
 	Env           string        `yaml:"env"  env:"ENV" env-default:"local" env-required:"true"`
 	AccessTTL     time.Duration `yaml:"access_ttl" env-required:"true"`
-	AccessSecret  string        `yaml:"access_secret" env-required:"true"`
+	AccessSecret  string        `yaml:"access_secret"`
+	AccessKeys    KeyRing       `yaml:"access_keys"`
 	RefreshTTL    time.Duration `yaml:"refresh_ttl" env-required:"true"`
-	RefreshSecret string        `yaml:"refresh_secret" env-required:"true"`
+	RefreshSecret string        `yaml:"refresh_secret"`
+	RefreshKeys   KeyRing       `yaml:"refresh_keys"`
+	Issuer        string        `yaml:"issuer" env-default:"auth-api"`
+	Audiences     []string      `yaml:"audiences" env-required:"true"`
+	Revocation    Revocation    `yaml:"revocation"`
+	Mailer        Mailer        `yaml:"mailer"`
+	Verification  Verification  `yaml:"email_verification"`
+	PasswordReset PasswordReset `yaml:"password_reset"`
+	Deletion      Deletion      `yaml:"account_deletion"`
+	MFA           MFA           `yaml:"mfa"`
+	WebAuthn      WebAuthn      `yaml:"webauthn"`
+	LoginThrottle LoginThrottle `yaml:"login_throttle"`
+	RateLimit     RateLimit     `yaml:"rate_limit"`
+	Lockout       Lockout       `yaml:"lockout"`
+	Admin         Admin         `yaml:"admin"`
+	Introspection Introspection `yaml:"introspection"`
+	PasswordRules PasswordRules `yaml:"password_policy"`
+	BreachCheck   BreachCheck   `yaml:"breach_check"`
+	PasswordHash  PasswordHash  `yaml:"password_hash"`
+	Email         Email         `yaml:"email"`
+	DisplayName   DisplayName   `yaml:"display_name"`
 	GRPCConfig    `yaml:"grpc"`
+	HTTPConfig    `yaml:"http"`
 	Database      `yaml:"database"`
 }
 
+// KeyRing lists signing keys by id. The active key signs new tokens, the
+// rest only verify until their retires_at. An empty ring falls back to the
+// plain *_secret value.
+type KeyRing struct {
+	Active string `yaml:"active"`
+	Keys   []Key  `yaml:"keys"`
+}
+
+type Key struct {
+	ID             string    `yaml:"id"`
+	Algorithm      string    `yaml:"algorithm"`
+	Secret         string    `yaml:"secret"`
+	PrivateKeyPath string    `yaml:"private_key_path"`
+	RetiresAt      time.Time `yaml:"retires_at"`
+}
+
+// GRPCConfig.TrustedProxies lists the addresses or CIDR ranges of reverse
+// proxies in front of the server. Forwarding headers are ignored on
+// connections from anywhere else.
 type GRPCConfig struct {
-	Port    int           `yaml:"port" env-required:"true"`
-	Timeout time.Duration `yaml:"timeout" env-required:"true"`
+	Port           int           `yaml:"port" env-required:"true"`
+	Timeout        time.Duration `yaml:"timeout" env-required:"true"`
+	TrustedProxies []string      `yaml:"trusted_proxies"`
+}
+
+// Revocation selects where revoked access token ids are kept: "postgres"
+// shares them between instances behind an in-memory cache, "memory" keeps
+// them in process only.
+type Revocation struct {
+	Store string `yaml:"store" env-default:"postgres"`
+}
+
+// Mailer picks how emails are delivered: "log" and "file" are meant for
+// local development, "smtp" sends real mail. The log driver is refused
+// outside env local. Timeout bounds a single SMTP delivery.
+type Mailer struct {
+	Driver   string        `yaml:"driver" env-default:"log"`
+	From     string        `yaml:"from" env-default:"no-reply@localhost"`
+	Dir      string        `yaml:"dir" env-default:"./mail"`
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port" env-default:"587"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	Timeout  time.Duration `yaml:"timeout" env-default:"30s"`
+}
+
+type Verification struct {
+	TTL       time.Duration `yaml:"ttl" env-default:"24h"`
+	URL       string        `yaml:"url" env-default:"http://localhost:3000/verify-email?token=%s"`
+	ChangeURL string        `yaml:"change_url" env-default:"http://localhost:3000/confirm-email?token=%s"`
+	Required  bool          `yaml:"required" env-default:"false"`
+}
+
+type PasswordReset struct {
+	TTL time.Duration `yaml:"ttl" env-default:"30m"`
+	URL string        `yaml:"url" env-default:"http://localhost:3000/reset-password?token=%s"`
+}
+
+// Deletion controls how long soft deleted accounts are kept before they are
+// purged together with their sessions and audit events. PurgeInterval also
+// paces the removal of other expired rows.
+type Deletion struct {
+	Retention     time.Duration `yaml:"retention" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// MFA configures TOTP two-factor login. EncryptionKey is a base64 encoded
+// 32 byte key used to encrypt TOTP secrets at rest; TOTP is disabled while it
+// is empty.
+type MFA struct {
+	EncryptionKey string        `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY"`
+	Issuer        string        `yaml:"issuer" env-default:"Deimos"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+}
+
+// WebAuthn describes the relying party passkeys are bound to. Origins lists
+// every web origin allowed to run the ceremonies, RPID must be their
+// registrable domain. With UserVerification "preferred" a passkey without
+// user verification is only a first factor and TOTP users still get an MFA
+// challenge.
+type WebAuthn struct {
+	RPID             string        `yaml:"rp_id" env-default:"localhost"`
+	RPName           string        `yaml:"rp_name" env-default:"Deimos"`
+	Origins          []string      `yaml:"origins" env-default:"http://localhost:3000"`
+	UserVerification string        `yaml:"user_verification" env-default:"required"`
+	ChallengeTTL     time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+}
+
+// LoginThrottle limits failed logins per account and per client address.
+// Store is "postgres" to share counters between instances or "memory".
+type LoginThrottle struct {
+	Store   string         `yaml:"store" env-default:"postgres"`
+	Account ThrottlePolicy `yaml:"account"`
+	IP      ThrottlePolicy `yaml:"ip"`
+}
+
+// ThrottlePolicy allows free_attempts failures within window, then blocks for
+// base_delay, doubling with every further failure up to max_delay.
+type ThrottlePolicy struct {
+	FreeAttempts int           `yaml:"free_attempts" env-default:"5"`
+	BaseDelay    time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay     time.Duration `yaml:"max_delay" env-default:"15m"`
+	Window       time.Duration `yaml:"window" env-default:"15m"`
+}
+
+// RateLimit configures token buckets for gRPC methods. Methods are keyed by
+// full name ("/package.Service/Method") or bare method name; methods not
+// listed use Default unless its rate is zero. MaxBuckets bounds memory under
+// a flood of distinct clients, new clients are refused while it is reached.
+type RateLimit struct {
+	Enabled    bool                  `yaml:"enabled" env-default:"true"`
+	MaxBuckets int                   `yaml:"max_buckets" env-default:"100000"`
+	Default    RateBudget            `yaml:"default"`
+	Methods    map[string]RateBudget `yaml:"methods"`
+}
+
+// RateBudget refills rate tokens per second up to burst. Key is "ip" or
+// "subject", the latter falling back to ip for anonymous calls.
+type RateBudget struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+	Key   string  `yaml:"key"`
+}
+
+// Lockout locks an account after threshold consecutive wrong passwords.
+// A zero threshold disables it.
+type Lockout struct {
+	Threshold int           `yaml:"threshold" env-default:"10"`
+	Duration  time.Duration `yaml:"duration" env-default:"1h"`
+}
+
+// Admin holds the key admin RPCs have to present. Admin RPCs are refused
+// while it is empty.
+type Admin struct {
+	APIKey string `yaml:"api_key" env:"ADMIN_API_KEY"`
+}
+
+// Introspection maps the client ids of resource servers allowed to call
+// Introspect to their secrets. Introspect is refused while it is empty.
+type Introspection struct {
+	Clients map[string]string `yaml:"clients" env:"INTROSPECTION_CLIENTS"`
+}
+
+// PasswordRules is applied to passwords set on registration, reset and
+// change. MinScore is the minimal strength on a 0..4 scale, 0 disables it.
+type PasswordRules struct {
+	MinLength      int  `yaml:"min_length" env-default:"8"`
+	MaxLength      int  `yaml:"max_length" env-default:"64"`
+	RequireLower   bool `yaml:"require_lower" env-default:"false"`
+	RequireUpper   bool `yaml:"require_upper" env-default:"false"`
+	RequireDigit   bool `yaml:"require_digit" env-default:"false"`
+	RequireSymbol  bool `yaml:"require_symbol" env-default:"false"`
+	RejectPersonal bool `yaml:"reject_personal" env-default:"true"`
+	RejectCommon   bool `yaml:"reject_common" env-default:"true"`
+	MinScore       int  `yaml:"min_score" env-default:"2"`
+}
+
+// BreachCheck rejects passwords known from data breaches. Driver is "http"
+// for a k-anonymity range endpoint, URL being a format string receiving the
+// hash prefix, or "file" for a local sorted list of SHA-1 hashes at Path.
+// An empty driver disables the check. FailOpen accepts passwords while the
+// check is failing instead of refusing them.
+type BreachCheck struct {
+	Driver   string        `yaml:"driver"`
+	URL      string        `yaml:"url" env-default:"https://api.pwnedpasswords.com/range/%s"`
+	Path     string        `yaml:"path"`
+	Timeout  time.Duration `yaml:"timeout" env-default:"2s"`
+	FailOpen bool          `yaml:"fail_open" env-default:"true"`
+}
+
+// PasswordHash picks the algorithm for new password hashes, "argon2id" or
+// "bcrypt". Stored hashes made with another algorithm or other costs are
+// replaced on the next successful login.
+type PasswordHash struct {
+	Algorithm  string `yaml:"algorithm" env-default:"argon2id"`
+	Argon2     Argon2 `yaml:"argon2"`
+	BcryptCost int    `yaml:"bcrypt_cost" env-default:"10"`
+	Pepper     Pepper `yaml:"pepper"`
+}
+
+// Pepper lists base64 encoded HMAC keys of at least 32 bytes by version.
+// Current picks the key for new hashes, 0 disables the pepper. Hashes made
+// with an older version are rehashed on the next successful login, so a
+// version may only be removed once no stored hash uses it.
+type Pepper struct {
+	Current int            `yaml:"current" env-default:"0"`
+	Keys    map[int]string `yaml:"keys"`
+}
+
+// Argon2 costs, memory is in KiB.
+type Argon2 struct {
+	Memory      uint32 `yaml:"memory" env-default:"19456"`
+	Iterations  uint32 `yaml:"iterations" env-default:"2"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"1"`
+	SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength   uint32 `yaml:"key_length" env-default:"32"`
+}
+
+// Email controls address normalization. Addresses are always lowercased to
+// agree with the case-insensitive email column. BlockDisposable refuses the
+// bundled list of throwaway mail domains, BlockedDomains adds to it.
+type Email struct {
+	BlockDisposable bool     `yaml:"block_disposable" env-default:"true"`
+	BlockedDomains  []string `yaml:"blocked_domains"`
+}
+
+// DisplayName limits user names. Lengths are counted in characters after
+// normalization. Reserved names are refused regardless of case, spacing,
+// punctuation and look-alike characters.
+type DisplayName struct {
+	MinLength int      `yaml:"min_length" env-default:"1"`
+	MaxLength int      `yaml:"max_length" env-default:"64"`
+	Reserved  []string `yaml:"reserved" env-default:"admin,administrator,root,system,support,moderator,deimos"`
+}
+
+type HTTPConfig struct {
+	Port    int           `yaml:"port" env-default:"8080"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
 }
 
 type Database struct {
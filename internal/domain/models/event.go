@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventPasswordReset     = "password_reset"
+	EventPasswordChanged   = "password_changed"
+	EventEmailChanged      = "email_changed"
+	EventAccountDeleted    = "account_deleted"
+	EventRecoveryCodeUsed  = "recovery_code_used"
+	EventAccountLocked     = "account_locked"
+	EventAccountUnlocked   = "account_unlocked"
+)
+
+type AuditEvent struct {
+	UserID    string
+	Event     string
+	Metadata  map[string]string
+	CreatedAt time.Time
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type TOTP struct {
+	UserID          string
+	SecretEncrypted []byte
+	ConfirmedAt     *time.Time
+	LastUsedStep    int64
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+type RecoveryCode struct {
+	ID       string
+	UserID   string
+	CodeHash []byte
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+type Passkey struct {
+	ID         []byte
+	UserID     string
+	PublicKey  []byte
+	SignCount  uint32
+	AAGUID     []byte
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// WebAuthnChallenge is an issued ceremony challenge. UserID is empty for
+// login, where the user is only known from the credential.
+type WebAuthnChallenge struct {
+	Challenge []byte
+	UserID    string
+	Ceremony  string
+	ExpiresAt time.Time
+}
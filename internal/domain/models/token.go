@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	TokenID       string
+	UserID        string
+	FamilyID      string
+	ParentTokenID string
+	UserAgent     string
+	ClientIP      string
+	DeviceName    string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	LastUsedAt    time.Time
+	RotatedAt     *time.Time
+}
+
+type Session struct {
+	ID         string
+	UserAgent  string
+	ClientIP   string
+	DeviceName string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+type Introspection struct {
+	Active    bool
+	Subject   string
+	ClientID  string
+	TokenType string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+}
@@ -3,11 +3,20 @@ package models
 import "time"
 
 type UserModel struct {
-	ID           string
-	Email        string
-	Name         string
-	PasswordHash []byte
-	CreatedAt    time.Time
+	ID              string
+	Email           string
+	Name            string
+	PasswordHash    []byte
+	PepperVersion   int
+	CreatedAt       time.Time
+	EmailVerifiedAt *time.Time
+	TokenVersion    int
+	FailedAttempts  int
+	LockedUntil     *time.Time
+}
+
+func (u *UserModel) Locked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
 }
 
 type UserInfo struct {
@@ -24,9 +33,32 @@ type UserResponse struct {
 	CreatedAt    time.Time
 	Token        string
 	RefreshToken string
+	// MFAToken is set instead of the token pair when login needs a second
+	// factor.
+	MFAToken string
 }
 
 type Refresh struct {
 	Token        string
 	RefreshToken string
 }
+
+type EmailVerification struct {
+	UserID    string
+	Email     string
+	TokenHash []byte
+	ExpiresAt time.Time
+}
+
+type PasswordReset struct {
+	UserID    string
+	TokenHash []byte
+	ExpiresAt time.Time
+}
+
+type EmailChange struct {
+	UserID    string
+	NewEmail  string
+	TokenHash []byte
+	ExpiresAt time.Time
+}
@@ -2,23 +2,62 @@ package auth
 
 import (
 	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/displayname"
+	"auth-api/internal/lib/emailaddr"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/password"
 	"auth-api/internal/services/auth"
 	"auth-api/internal/storage"
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	auth_apiv1 "github.com/deeimos/proto-deimos-app/gen/go/auth-api"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Auth interface {
-	Login(ctx context.Context, email string, password string) (user *models.UserResponse, err error)
-	Register(ctx context.Context, name string, email string, password string) (*models.UserResponse, error)
+	Login(ctx context.Context, email string, password string, audience string) (user *models.UserResponse, err error)
+	Register(ctx context.Context, name string, email string, password string, audience string) (*models.UserResponse, error)
 	Refresh(ctx context.Context, refersh string) (*models.Refresh, error)
-	GetUser(ctx context.Context, token string) (*models.UserInfo, error)
+	GetUser(ctx context.Context, token string, audience string) (*models.UserInfo, error)
+	Logout(ctx context.Context, refreshToken string, accessToken string) error
+	LogoutAll(ctx context.Context, token string) error
+	ListSessions(ctx context.Context, token string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, token string, sessionID string) error
+	JWKS(ctx context.Context) jwt.JSONWebKeySet
+	Introspect(ctx context.Context, clientID string, clientSecret string, token string, tokenTypeHint string) (*models.Introspection, error)
+	SendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string, keepSession bool) (*models.Refresh, error)
+	UpdateProfile(ctx context.Context, token string, name string) (*models.UserInfo, error)
+	ChangeEmail(ctx context.Context, token string, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	DeleteAccount(ctx context.Context, token string, password string) error
+	ExportMyData(ctx context.Context, token string) ([]byte, error)
+	EnrollTOTP(ctx context.Context, token string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, token string, code string) ([]string, error)
+	VerifyMFA(ctx context.Context, mfaToken string, code string) (*models.UserResponse, error)
+	RegenerateRecoveryCodes(ctx context.Context, token string) ([]string, error)
+	RecoveryCodesRemaining(ctx context.Context, token string) (int, error)
+	BeginPasskeyRegistration(ctx context.Context, token string) ([]byte, error)
+	FinishPasskeyRegistration(ctx context.Context, token string, clientDataJSON []byte, attestationObject []byte, name string) ([]byte, error)
+	BeginPasskeyLogin(ctx context.Context) ([]byte, error)
+	FinishPasskeyLogin(ctx context.Context, credentialID []byte, clientDataJSON []byte, authenticatorData []byte, signature []byte, userHandle []byte, audience string) (*models.UserResponse, error)
+	LockUser(ctx context.Context, adminKey string, userID string, until time.Time) error
+	UnlockUser(ctx context.Context, adminKey string, userID string) error
 }
 
 type serverApi struct {
@@ -35,11 +74,23 @@ func (s *serverApi) Login(ctx context.Context, req *auth_apiv1.LoginRequest) (*a
 		return nil, err
 	}
 
-	user, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword())
+	user, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), req.GetAudience())
 	if err != nil {
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, accountLocked(err)
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, throttled(ctx, err)
+		}
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "Неверный логин или пароль")
 		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "Подтвердите адрес электронной почты")
+		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "audience: Недопустимая аудитория")
+		}
 		return nil, status.Error(codes.Internal, "internal Error")
 	}
 	return &auth_apiv1.LoginResponse{
@@ -49,6 +100,8 @@ func (s *serverApi) Login(ctx context.Context, req *auth_apiv1.LoginRequest) (*a
 		CreatedAt:    timestamppb.New(user.CreatedAt),
 		Token:        user.Token,
 		RefreshToken: user.RefreshToken,
+		MfaRequired:  user.MFAToken != "",
+		MfaToken:     user.MFAToken,
 	}, nil
 }
 
@@ -56,11 +109,31 @@ func (s *serverApi) Register(ctx context.Context, req *auth_apiv1.RegisterReques
 	if err := validateRegister(req); err != nil {
 		return nil, err
 	}
-	user, err := s.auth.Register(ctx, req.GetName(), req.GetEmail(), req.GetPassword())
+	user, err := s.auth.Register(ctx, req.GetName(), req.GetEmail(), req.GetPassword(), req.GetAudience())
 	if err != nil {
+		var invalidName *displayname.Violation
+		if errors.As(err, &invalidName) {
+			return nil, nameViolation(invalidName)
+		}
+		var violation *password.Violation
+		if errors.As(err, &violation) {
+			return nil, passwordViolation(violation)
+		}
+		if errors.Is(err, auth.ErrBreachCheckFailed) {
+			return nil, status.Error(codes.Unavailable, "Не удалось проверить пароль, попробуйте позже")
+		}
 		if errors.Is(err, storage.ErrUserExists) {
 			return nil, status.Error(codes.InvalidArgument, "email: Адрес электронной почты уже занят")
 		}
+		if errors.Is(err, emailaddr.ErrInvalid) {
+			return nil, status.Error(codes.InvalidArgument, "email: Некорректный адрес электронной почты")
+		}
+		if errors.Is(err, emailaddr.ErrDisposable) {
+			return nil, status.Error(codes.InvalidArgument, "email: Временные почтовые адреса не поддерживаются")
+		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "audience: Недопустимая аудитория")
+		}
 
 		return nil, status.Error(codes.Internal, "internal Error")
 	}
@@ -92,11 +165,14 @@ func (s *serverApi) GetUser(ctx context.Context, req *auth_apiv1.GetUserRequest)
 	if req.GetToken() == "" {
 		return nil, status.Error(codes.InvalidArgument, "Отсутствует токен")
 	}
-	user, err := s.auth.GetUser(ctx, req.GetToken())
+	user, err := s.auth.GetUser(ctx, req.GetToken(), req.GetAudience())
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidToken) {
 			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
 		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "audience: Недопустимая аудитория")
+		}
 		return nil, status.Error(codes.Internal, "internal Error")
 	}
 	return &auth_apiv1.GetUserResponse{
@@ -107,6 +183,627 @@ func (s *serverApi) GetUser(ctx context.Context, req *auth_apiv1.GetUserRequest)
 	}, nil
 }
 
+func (s *serverApi) Logout(ctx context.Context, req *auth_apiv1.LogoutRequest) (*auth_apiv1.LogoutResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if err := s.auth.Logout(ctx, req.GetRefreshToken(), req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.LogoutResponse{}, nil
+}
+
+func (s *serverApi) LogoutAll(ctx context.Context, req *auth_apiv1.LogoutAllRequest) (*auth_apiv1.LogoutAllResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if err := s.auth.LogoutAll(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.LogoutAllResponse{}, nil
+}
+
+func (s *serverApi) ListSessions(ctx context.Context, req *auth_apiv1.ListSessionsRequest) (*auth_apiv1.ListSessionsResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	sessions, err := s.auth.ListSessions(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+
+	resp := &auth_apiv1.ListSessionsResponse{Sessions: make([]*auth_apiv1.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &auth_apiv1.Session{
+			Id:         session.ID,
+			UserAgent:  session.UserAgent,
+			ClientIp:   session.ClientIP,
+			DeviceName: session.DeviceName,
+			CreatedAt:  timestamppb.New(session.CreatedAt),
+			LastUsedAt: timestamppb.New(session.LastUsedAt),
+			ExpiresAt:  timestamppb.New(session.ExpiresAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *serverApi) RevokeSession(ctx context.Context, req *auth_apiv1.RevokeSessionRequest) (*auth_apiv1.RevokeSessionResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if _, err := uuid.Parse(req.GetSessionId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "session_id: Неверный идентификатор сессии")
+	}
+	if err := s.auth.RevokeSession(ctx, req.GetToken(), req.GetSessionId()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "Сессия не найдена")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.RevokeSessionResponse{}, nil
+}
+
+func (s *serverApi) GetJWKS(ctx context.Context, req *auth_apiv1.GetJWKSRequest) (*auth_apiv1.GetJWKSResponse, error) {
+	set := s.auth.JWKS(ctx)
+
+	resp := &auth_apiv1.GetJWKSResponse{Keys: make([]*auth_apiv1.JSONWebKey, 0, len(set.Keys))}
+	for _, key := range set.Keys {
+		resp.Keys = append(resp.Keys, &auth_apiv1.JSONWebKey{
+			Kty: key.Kty,
+			Use: key.Use,
+			Alg: key.Alg,
+			Kid: key.Kid,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+	return resp, nil
+}
+
+func (s *serverApi) Introspect(ctx context.Context, req *auth_apiv1.IntrospectRequest) (*auth_apiv1.IntrospectResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Отсутствует токен")
+	}
+	result, err := s.auth.Introspect(ctx, req.GetClientId(), req.GetClientSecret(), req.GetToken(), req.GetTokenTypeHint())
+	if err != nil {
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Недостаточно прав")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	if !result.Active {
+		return &auth_apiv1.IntrospectResponse{Active: false}, nil
+	}
+	return &auth_apiv1.IntrospectResponse{
+		Active:    true,
+		Sub:       result.Subject,
+		Exp:       result.ExpiresAt.Unix(),
+		Iat:       result.IssuedAt.Unix(),
+		ClientId:  result.ClientID,
+		TokenType: result.TokenType,
+	}, nil
+}
+
+func (s *serverApi) SendVerificationEmail(ctx context.Context, req *auth_apiv1.SendVerificationEmailRequest) (*auth_apiv1.SendVerificationEmailResponse, error) {
+	if req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email: Введите email")
+	}
+	if err := s.auth.SendVerificationEmail(ctx, req.GetEmail()); err != nil {
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.SendVerificationEmailResponse{}, nil
+}
+
+func (s *serverApi) VerifyEmail(ctx context.Context, req *auth_apiv1.VerifyEmailRequest) (*auth_apiv1.VerifyEmailResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Отсутствует токен")
+	}
+	if err := s.auth.VerifyEmail(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Ссылка недействительна или устарела")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.VerifyEmailResponse{}, nil
+}
+
+func (s *serverApi) RequestPasswordReset(ctx context.Context, req *auth_apiv1.RequestPasswordResetRequest) (*auth_apiv1.RequestPasswordResetResponse, error) {
+	if req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email: Введите email")
+	}
+	if err := s.auth.RequestPasswordReset(ctx, req.GetEmail()); err != nil {
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.RequestPasswordResetResponse{}, nil
+}
+
+func (s *serverApi) ResetPassword(ctx context.Context, req *auth_apiv1.ResetPasswordRequest) (*auth_apiv1.ResetPasswordResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Отсутствует токен")
+	}
+	if req.GetNewPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password: Введите пароль")
+	}
+	if err := s.auth.ResetPassword(ctx, req.GetToken(), req.GetNewPassword()); err != nil {
+		var violation *password.Violation
+		if errors.As(err, &violation) {
+			return nil, passwordViolation(violation)
+		}
+		if errors.Is(err, auth.ErrBreachCheckFailed) {
+			return nil, status.Error(codes.Unavailable, "Не удалось проверить пароль, попробуйте позже")
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Ссылка недействительна или устарела")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.ResetPasswordResponse{}, nil
+}
+
+func (s *serverApi) ChangePassword(ctx context.Context, req *auth_apiv1.ChangePasswordRequest) (*auth_apiv1.ChangePasswordResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetOldPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "old_password: Введите текущий пароль")
+	}
+	if req.GetNewPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password: Введите пароль")
+	}
+	tokens, err := s.auth.ChangePassword(ctx, req.GetToken(), req.GetOldPassword(), req.GetNewPassword(), req.GetKeepSession())
+	if err != nil {
+		var violation *password.Violation
+		if errors.As(err, &violation) {
+			return nil, passwordViolation(violation)
+		}
+		if errors.Is(err, auth.ErrBreachCheckFailed) {
+			return nil, status.Error(codes.Unavailable, "Не удалось проверить пароль, попробуйте позже")
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, accountLocked(err)
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, throttled(ctx, err)
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "old_password: Неверный пароль")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	if tokens == nil {
+		return &auth_apiv1.ChangePasswordResponse{}, nil
+	}
+	return &auth_apiv1.ChangePasswordResponse{Token: tokens.Token, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *serverApi) UpdateProfile(ctx context.Context, req *auth_apiv1.UpdateProfileRequest) (*auth_apiv1.UpdateProfileResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name: Введите имя")
+	}
+	user, err := s.auth.UpdateProfile(ctx, req.GetToken(), req.GetName())
+	if err != nil {
+		var violation *displayname.Violation
+		if errors.As(err, &violation) {
+			return nil, nameViolation(violation)
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.UpdateProfileResponse{
+		Id:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}, nil
+}
+
+func (s *serverApi) ChangeEmail(ctx context.Context, req *auth_apiv1.ChangeEmailRequest) (*auth_apiv1.ChangeEmailResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetNewEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email: Введите email")
+	}
+	if err := s.auth.ChangeEmail(ctx, req.GetToken(), req.GetNewEmail()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, storage.ErrUserExists) {
+			return nil, status.Error(codes.InvalidArgument, "email: Адрес электронной почты уже занят")
+		}
+		if errors.Is(err, emailaddr.ErrInvalid) {
+			return nil, status.Error(codes.InvalidArgument, "email: Некорректный адрес электронной почты")
+		}
+		if errors.Is(err, emailaddr.ErrDisposable) {
+			return nil, status.Error(codes.InvalidArgument, "email: Временные почтовые адреса не поддерживаются")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.ChangeEmailResponse{}, nil
+}
+
+func (s *serverApi) ConfirmEmailChange(ctx context.Context, req *auth_apiv1.ConfirmEmailChangeRequest) (*auth_apiv1.ConfirmEmailChangeResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Отсутствует токен")
+	}
+	if err := s.auth.ConfirmEmailChange(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Ссылка недействительна или устарела")
+		}
+		if errors.Is(err, storage.ErrUserExists) {
+			return nil, status.Error(codes.InvalidArgument, "email: Адрес электронной почты уже занят")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.ConfirmEmailChangeResponse{}, nil
+}
+
+func (s *serverApi) DeleteAccount(ctx context.Context, req *auth_apiv1.DeleteAccountRequest) (*auth_apiv1.DeleteAccountResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password: Введите пароль")
+	}
+	if err := s.auth.DeleteAccount(ctx, req.GetToken(), req.GetPassword()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, accountLocked(err)
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, throttled(ctx, err)
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "password: Неверный пароль")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.DeleteAccountResponse{}, nil
+}
+
+func (s *serverApi) ExportMyData(ctx context.Context, req *auth_apiv1.ExportMyDataRequest) (*auth_apiv1.ExportMyDataResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	data, err := s.auth.ExportMyData(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.ExportMyDataResponse{Data: data}, nil
+}
+
+func (s *serverApi) EnrollTOTP(ctx context.Context, req *auth_apiv1.EnrollTOTPRequest) (*auth_apiv1.EnrollTOTPResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	enrollment, err := s.auth.EnrollTOTP(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrMFADisabled) {
+			return nil, status.Error(codes.Unimplemented, "Двухфакторная аутентификация отключена")
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, "Двухфакторная аутентификация уже включена")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.EnrollTOTPResponse{Secret: enrollment.Secret, Uri: enrollment.URI}, nil
+}
+
+func (s *serverApi) ConfirmTOTP(ctx context.Context, req *auth_apiv1.ConfirmTOTPRequest) (*auth_apiv1.ConfirmTOTPResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code: Введите код")
+	}
+	recoveryCodes, err := s.auth.ConfirmTOTP(ctx, req.GetToken(), req.GetCode())
+	if err != nil {
+		if errors.Is(err, auth.ErrMFADisabled) {
+			return nil, status.Error(codes.Unimplemented, "Двухфакторная аутентификация отключена")
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.InvalidArgument, "code: Неверный код")
+		}
+		if errors.Is(err, auth.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, "Двухфакторная аутентификация не настроена")
+		}
+		if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, "Двухфакторная аутентификация уже включена")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *serverApi) VerifyMFA(ctx context.Context, req *auth_apiv1.VerifyMFARequest) (*auth_apiv1.VerifyMFAResponse, error) {
+	if req.GetMfaToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code: Введите код")
+	}
+	user, err := s.auth.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		if errors.Is(err, auth.ErrMFADisabled) {
+			return nil, status.Error(codes.Unimplemented, "Двухфакторная аутентификация отключена")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, accountLocked(err)
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, throttled(ctx, err)
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.InvalidArgument, "code: Неверный код")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.VerifyMFAResponse{
+		Id:           user.ID,
+		Email:        user.Email,
+		Name:         user.Name,
+		CreatedAt:    timestamppb.New(user.CreatedAt),
+		Token:        user.Token,
+		RefreshToken: user.RefreshToken,
+	}, nil
+}
+
+func (s *serverApi) RegenerateRecoveryCodes(ctx context.Context, req *auth_apiv1.RegenerateRecoveryCodesRequest) (*auth_apiv1.RegenerateRecoveryCodesResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	recoveryCodes, err := s.auth.RegenerateRecoveryCodes(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, "Двухфакторная аутентификация не настроена")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.RegenerateRecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *serverApi) GetRecoveryCodesCount(ctx context.Context, req *auth_apiv1.GetRecoveryCodesCountRequest) (*auth_apiv1.GetRecoveryCodesCountResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	remaining, err := s.auth.RecoveryCodesRemaining(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.GetRecoveryCodesCountResponse{Remaining: int32(remaining)}, nil
+}
+
+func (s *serverApi) BeginPasskeyRegistration(ctx context.Context, req *auth_apiv1.BeginPasskeyRegistrationRequest) (*auth_apiv1.BeginPasskeyRegistrationResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	options, err := s.auth.BeginPasskeyRegistration(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.BeginPasskeyRegistrationResponse{OptionsJson: string(options)}, nil
+}
+
+func (s *serverApi) FinishPasskeyRegistration(ctx context.Context, req *auth_apiv1.FinishPasskeyRegistrationRequest) (*auth_apiv1.FinishPasskeyRegistrationResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.Unauthenticated, "Отсутствует токен")
+	}
+	if len(req.GetClientDataJson()) == 0 || len(req.GetAttestationObject()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "credential: Отсутствуют данные ключа")
+	}
+	credentialID, err := s.auth.FinishPasskeyRegistration(ctx, req.GetToken(), req.GetClientDataJson(), req.GetAttestationObject(), req.GetName())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Неверный или недействительный токен")
+		}
+		if errors.Is(err, auth.ErrInvalidPasskey) {
+			return nil, status.Error(codes.InvalidArgument, "credential: Не удалось проверить ключ доступа")
+		}
+		if errors.Is(err, auth.ErrPasskeyExists) {
+			return nil, status.Error(codes.AlreadyExists, "credential: Ключ доступа уже зарегистрирован")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.FinishPasskeyRegistrationResponse{
+		CredentialId: base64.RawURLEncoding.EncodeToString(credentialID),
+	}, nil
+}
+
+func (s *serverApi) BeginPasskeyLogin(ctx context.Context, req *auth_apiv1.BeginPasskeyLoginRequest) (*auth_apiv1.BeginPasskeyLoginResponse, error) {
+	options, err := s.auth.BeginPasskeyLogin(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.BeginPasskeyLoginResponse{OptionsJson: string(options)}, nil
+}
+
+func (s *serverApi) FinishPasskeyLogin(ctx context.Context, req *auth_apiv1.FinishPasskeyLoginRequest) (*auth_apiv1.FinishPasskeyLoginResponse, error) {
+	if len(req.GetCredentialId()) == 0 || len(req.GetClientDataJson()) == 0 ||
+		len(req.GetAuthenticatorData()) == 0 || len(req.GetSignature()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "credential: Отсутствуют данные ключа")
+	}
+	user, err := s.auth.FinishPasskeyLogin(ctx, req.GetCredentialId(), req.GetClientDataJson(),
+		req.GetAuthenticatorData(), req.GetSignature(), req.GetUserHandle(), req.GetAudience())
+	if err != nil {
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, accountLocked(err)
+		}
+		if errors.Is(err, auth.ErrInvalidPasskey) {
+			return nil, status.Error(codes.Unauthenticated, "Не удалось проверить ключ доступа")
+		}
+		if errors.Is(err, auth.ErrInvalidAudience) {
+			return nil, status.Error(codes.InvalidArgument, "audience: Недопустимая аудитория")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.FinishPasskeyLoginResponse{
+		Id:           user.ID,
+		Email:        user.Email,
+		Name:         user.Name,
+		CreatedAt:    timestamppb.New(user.CreatedAt),
+		Token:        user.Token,
+		RefreshToken: user.RefreshToken,
+	}, nil
+}
+
+func (s *serverApi) LockUser(ctx context.Context, req *auth_apiv1.LockUserRequest) (*auth_apiv1.LockUserResponse, error) {
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user_id: Неверный идентификатор пользователя")
+	}
+	var until time.Time
+	if req.GetUntil() != nil {
+		until = req.GetUntil().AsTime()
+	}
+	if err := s.auth.LockUser(ctx, req.GetAdminKey(), req.GetUserId(), until); err != nil {
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Недостаточно прав")
+		}
+		if errors.Is(err, auth.ErrInvalidLockUntil) {
+			return nil, status.Error(codes.InvalidArgument, "until: Срок блокировки должен быть в будущем")
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "Пользователь не найден")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.LockUserResponse{}, nil
+}
+
+func (s *serverApi) UnlockUser(ctx context.Context, req *auth_apiv1.UnlockUserRequest) (*auth_apiv1.UnlockUserResponse, error) {
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user_id: Неверный идентификатор пользователя")
+	}
+	if err := s.auth.UnlockUser(ctx, req.GetAdminKey(), req.GetUserId()); err != nil {
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Недостаточно прав")
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "Пользователь не найден")
+		}
+		return nil, status.Error(codes.Internal, "internal Error")
+	}
+	return &auth_apiv1.UnlockUserResponse{}, nil
+}
+
+func accountLocked(err error) error {
+	var lockedErr *auth.AccountLockedError
+	if errors.As(err, &lockedErr) && !lockedErr.Permanent() {
+		return status.Error(codes.PermissionDenied,
+			fmt.Sprintf("Учетная запись заблокирована до %s", lockedErr.Until.UTC().Format("02.01.2006 15:04 MST")))
+	}
+	return status.Error(codes.PermissionDenied, "Учетная запись заблокирована")
+}
+
+// throttled reports a blocked attempt with ResourceExhausted, carrying the
+// wait both as RetryInfo details and in the retry-after header.
+func throttled(ctx context.Context, err error) error {
+	var throttledErr *auth.ThrottledError
+	if !errors.As(err, &throttledErr) {
+		return status.Error(codes.ResourceExhausted, "Слишком много попыток, попробуйте позже")
+	}
+
+	retryAfter := max(throttledErr.RetryAfter.Round(time.Second), time.Second)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(retryAfter.Seconds()))))
+
+	st, detailsErr := status.New(codes.ResourceExhausted,
+		fmt.Sprintf("Слишком много попыток, повторите через %s", retryAfter)).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if detailsErr != nil {
+		return status.Error(codes.ResourceExhausted, "Слишком много попыток, попробуйте позже")
+	}
+	return st.Err()
+}
+
+func passwordViolation(violation *password.Violation) error {
+	var msg string
+	switch {
+	case errors.Is(violation, password.ErrTooShort):
+		msg = fmt.Sprintf("Пароль должен содержать не менее %d символов", violation.Limit)
+	case errors.Is(violation, password.ErrTooLong):
+		msg = fmt.Sprintf("Пароль должен содержать не более %d символов", violation.Limit)
+	case errors.Is(violation, password.ErrTooManyBytes):
+		msg = "Пароль слишком длинный"
+	case errors.Is(violation, password.ErrMissingLower):
+		msg = "Пароль должен содержать строчную букву"
+	case errors.Is(violation, password.ErrMissingUpper):
+		msg = "Пароль должен содержать заглавную букву"
+	case errors.Is(violation, password.ErrMissingDigit):
+		msg = "Пароль должен содержать цифру"
+	case errors.Is(violation, password.ErrMissingSymbol):
+		msg = "Пароль должен содержать специальный символ"
+	case errors.Is(violation, password.ErrContainsPersonal):
+		msg = "Пароль не должен содержать имя или адрес электронной почты"
+	case errors.Is(violation, password.ErrCommon):
+		msg = "Этот пароль слишком распространен"
+	case errors.Is(violation, password.ErrBreached):
+		msg = "Этот пароль встречается в утечках данных, выберите другой"
+	default:
+		msg = "Пароль слишком простой"
+	}
+	return status.Error(codes.InvalidArgument, "password: "+msg)
+}
+
+func nameViolation(violation *displayname.Violation) error {
+	var msg string
+	switch {
+	case errors.Is(violation, displayname.ErrTooShort):
+		msg = fmt.Sprintf("Имя должно содержать не менее %d символов", violation.Limit)
+	case errors.Is(violation, displayname.ErrTooLong):
+		msg = fmt.Sprintf("Имя должно содержать не более %d символов", violation.Limit)
+	case errors.Is(violation, displayname.ErrReserved):
+		msg = "Это имя зарезервировано"
+	default:
+		msg = "Имя содержит недопустимые символы"
+	}
+	return status.Error(codes.InvalidArgument, "name: "+msg)
+}
+
 func validateLogin(req *auth_apiv1.LoginRequest) error {
 	if req.GetEmail() == "" {
 		return status.Error(codes.InvalidArgument, "email: Введите email")
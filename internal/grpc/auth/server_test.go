@@ -0,0 +1,989 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/displayname"
+	"auth-api/internal/lib/emailaddr"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/password"
+	"auth-api/internal/services/auth"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	auth_apiv1 "github.com/deeimos/proto-deimos-app/gen/go/auth-api"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuth returns err from the calls a test makes. Calls a test does not
+// expect are left to the embedded nil interface and panic.
+type fakeAuth struct {
+	Auth
+	err           error
+	jwks          jwt.JSONWebKeySet
+	introspection *models.Introspection
+}
+
+func (f *fakeAuth) Login(ctx context.Context, email string, password string, audience string) (*models.UserResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserResponse{ID: "user-1", Email: email, Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f *fakeAuth) Register(ctx context.Context, name string, email string, password string, audience string) (*models.UserResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserResponse{ID: "user-1", Email: email, Name: name, Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f *fakeAuth) Logout(ctx context.Context, refreshToken string, accessToken string) error {
+	return f.err
+}
+
+func (f *fakeAuth) LogoutAll(ctx context.Context, token string) error {
+	return f.err
+}
+
+func (f *fakeAuth) ListSessions(ctx context.Context, token string) ([]models.Session, error) {
+	return nil, f.err
+}
+
+func (f *fakeAuth) RevokeSession(ctx context.Context, token string, sessionID string) error {
+	return f.err
+}
+
+func (f *fakeAuth) GetUser(ctx context.Context, token string, audience string) (*models.UserInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserInfo{ID: "user-1", Email: "user@example.com", Name: "User"}, nil
+}
+
+func (f *fakeAuth) Introspect(ctx context.Context, clientID string, clientSecret string, token string, tokenTypeHint string) (*models.Introspection, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.introspection, nil
+}
+
+func (f *fakeAuth) SendVerificationEmail(ctx context.Context, email string) error {
+	return f.err
+}
+
+func (f *fakeAuth) VerifyEmail(ctx context.Context, token string) error {
+	return f.err
+}
+
+func (f *fakeAuth) RequestPasswordReset(ctx context.Context, email string) error {
+	return f.err
+}
+
+func (f *fakeAuth) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	return f.err
+}
+
+func (f *fakeAuth) ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string, keepSession bool) (*models.Refresh, error) {
+	if f.err != nil || !keepSession {
+		return nil, f.err
+	}
+	return &models.Refresh{Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f *fakeAuth) UpdateProfile(ctx context.Context, token string, name string) (*models.UserInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserInfo{ID: "user-1", Email: "user@example.com", Name: name}, nil
+}
+
+func (f *fakeAuth) ChangeEmail(ctx context.Context, token string, newEmail string) error {
+	return f.err
+}
+
+func (f *fakeAuth) ConfirmEmailChange(ctx context.Context, token string) error {
+	return f.err
+}
+
+func (f *fakeAuth) DeleteAccount(ctx context.Context, token string, password string) error {
+	return f.err
+}
+
+func (f *fakeAuth) ExportMyData(ctx context.Context, token string) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(`{"user":{}}`), nil
+}
+
+func (f *fakeAuth) EnrollTOTP(ctx context.Context, token string) (*models.TOTPEnrollment, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.TOTPEnrollment{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/Deimos:user@example.com"}, nil
+}
+
+func (f *fakeAuth) ConfirmTOTP(ctx context.Context, token string, code string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []string{"abcde-fghjk"}, nil
+}
+
+func (f *fakeAuth) VerifyMFA(ctx context.Context, mfaToken string, code string) (*models.UserResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserResponse{ID: "user-1", Email: "user@example.com", Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f *fakeAuth) RegenerateRecoveryCodes(ctx context.Context, token string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []string{"abcde-fghjk", "mnpqr-stuvw"}, nil
+}
+
+func (f *fakeAuth) RecoveryCodesRemaining(ctx context.Context, token string) (int, error) {
+	return 7, f.err
+}
+
+func (f *fakeAuth) BeginPasskeyRegistration(ctx context.Context, token string) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(`{"challenge":"Y2hhbGxlbmdl"}`), nil
+}
+
+func (f *fakeAuth) FinishPasskeyRegistration(ctx context.Context, token string, clientDataJSON []byte, attestationObject []byte, name string) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte{0xfb, 0xff}, nil
+}
+
+func (f *fakeAuth) BeginPasskeyLogin(ctx context.Context) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(`{"challenge":"Y2hhbGxlbmdl"}`), nil
+}
+
+func (f *fakeAuth) FinishPasskeyLogin(ctx context.Context, credentialID []byte, clientDataJSON []byte, authenticatorData []byte, signature []byte, userHandle []byte, audience string) (*models.UserResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.UserResponse{ID: "user-1", Email: "user@example.com", Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f *fakeAuth) LockUser(ctx context.Context, adminKey string, userID string, until time.Time) error {
+	return f.err
+}
+
+func (f *fakeAuth) UnlockUser(ctx context.Context, adminKey string, userID string) error {
+	return f.err
+}
+
+func (f *fakeAuth) JWKS(ctx context.Context) jwt.JSONWebKeySet {
+	return f.jwks
+}
+
+// wrapped mimics the service, which prefixes errors with the operation.
+func wrapped(err error) error {
+	return fmt.Errorf("auth.Op: %w", err)
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.LoginRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, nil, codes.OK},
+		{"no email", &auth_apiv1.LoginRequest{Password: "secret"}, nil, codes.InvalidArgument},
+		{"wrong password", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, wrapped(auth.ErrInvalidCredentials), codes.InvalidArgument},
+		{"unverified email", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, wrapped(auth.ErrEmailNotVerified), codes.FailedPrecondition},
+		{"unknown audience", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret", Audience: "other"}, wrapped(auth.ErrInvalidAudience), codes.InvalidArgument},
+		{"throttled", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, wrapped(&auth.ThrottledError{RetryAfter: time.Minute}), codes.ResourceExhausted},
+		{"locked", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, wrapped(&auth.AccountLockedError{Until: time.Now().Add(time.Hour)}), codes.PermissionDenied},
+		{"storage failure", &auth_apiv1.LoginRequest{Email: "user@example.com", Password: "secret"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.Login(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	valid := func() *auth_apiv1.RegisterRequest {
+		return &auth_apiv1.RegisterRequest{Name: "User", Email: "user@example.com", Password: "secret"}
+	}
+	noName := valid()
+	noName.Name = ""
+	tests := []struct {
+		name string
+		req  *auth_apiv1.RegisterRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", valid(), nil, codes.OK},
+		{"no name", noName, nil, codes.InvalidArgument},
+		{"taken email", valid(), wrapped(storage.ErrUserExists), codes.InvalidArgument},
+		{"unknown audience", valid(), wrapped(auth.ErrInvalidAudience), codes.InvalidArgument},
+		{"breach check failed", valid(), wrapped(auth.ErrBreachCheckFailed), codes.Unavailable},
+		{"invalid email", valid(), wrapped(emailaddr.ErrInvalid), codes.InvalidArgument},
+		{"disposable email", valid(), wrapped(emailaddr.ErrDisposable), codes.InvalidArgument},
+		{"long name", valid(), wrapped(&displayname.Violation{Err: displayname.ErrTooLong, Limit: 64}), codes.InvalidArgument},
+		{"storage failure", valid(), errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.Register(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.LogoutRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.LogoutRequest{RefreshToken: "refresh"}, nil, codes.OK},
+		{"no token", &auth_apiv1.LogoutRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.LogoutRequest{RefreshToken: "refresh"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"storage failure", &auth_apiv1.LogoutRequest{RefreshToken: "refresh"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.Logout(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.LogoutAllRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.LogoutAllRequest{Token: "access"}, nil, codes.OK},
+		{"no token", &auth_apiv1.LogoutAllRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.LogoutAllRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"storage failure", &auth_apiv1.LogoutAllRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.LogoutAll(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.GetUserRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.GetUserRequest{Token: "access", Audience: "app"}, nil, codes.OK},
+		{"no token", &auth_apiv1.GetUserRequest{}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.GetUserRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"unknown audience", &auth_apiv1.GetUserRequest{Token: "access", Audience: "other"}, wrapped(auth.ErrInvalidAudience), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.GetUserRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.GetUser(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	const sessionID = "0b6a1f0e-8d5c-4f43-9a57-3c3a4c1d2e5f"
+	tests := []struct {
+		name string
+		req  *auth_apiv1.RevokeSessionRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.RevokeSessionRequest{Token: "access", SessionId: sessionID}, nil, codes.OK},
+		{"no token", &auth_apiv1.RevokeSessionRequest{SessionId: sessionID}, nil, codes.Unauthenticated},
+		{"invalid session id", &auth_apiv1.RevokeSessionRequest{Token: "access", SessionId: "42"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.RevokeSessionRequest{Token: "access", SessionId: sessionID}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"unknown session", &auth_apiv1.RevokeSessionRequest{Token: "access", SessionId: sessionID}, wrapped(auth.ErrSessionNotFound), codes.NotFound},
+		{"storage failure", &auth_apiv1.RevokeSessionRequest{Token: "access", SessionId: sessionID}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.RevokeSession(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	key := jwt.JSONWebKey{Kty: "EC", Use: "sig", Alg: jwt.AlgES256, Kid: "k1", Crv: "P-256", X: "x", Y: "y"}
+	s := &serverApi{auth: &fakeAuth{jwks: jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{key}}}}
+
+	resp, err := s.GetJWKS(context.Background(), &auth_apiv1.GetJWKSRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetKeys()) != 1 {
+		t.Fatalf("keys = %v, want 1", resp.GetKeys())
+	}
+	got := resp.GetKeys()[0]
+	if got.GetKty() != key.Kty || got.GetAlg() != key.Alg || got.GetKid() != key.Kid || got.GetCrv() != key.Crv || got.GetX() != key.X || got.GetY() != key.Y {
+		t.Errorf("key = %v, want %+v", got, key)
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	issued := time.Now().Truncate(time.Second)
+	active := &models.Introspection{
+		Active:    true,
+		Subject:   "user-1",
+		ClientID:  "app",
+		TokenType: models.TokenTypeAccess,
+		IssuedAt:  issued,
+		ExpiresAt: issued.Add(time.Minute),
+	}
+
+	s := &serverApi{auth: &fakeAuth{introspection: active}}
+	resp, err := s.Introspect(context.Background(), &auth_apiv1.IntrospectRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetActive() || resp.GetSub() != "user-1" || resp.GetClientId() != "app" || resp.GetTokenType() != models.TokenTypeAccess ||
+		resp.GetIat() != issued.Unix() || resp.GetExp() != issued.Add(time.Minute).Unix() {
+		t.Errorf("response = %v", resp)
+	}
+
+	// Inactive tokens reveal nothing about themselves.
+	s = &serverApi{auth: &fakeAuth{introspection: &models.Introspection{Active: false, Subject: "user-1"}}}
+	resp, err = s.Introspect(context.Background(), &auth_apiv1.IntrospectRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetActive() || resp.GetSub() != "" {
+		t.Errorf("inactive response = %v", resp)
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.IntrospectRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.IntrospectRequest{}, nil, codes.InvalidArgument},
+		{"client rejected", &auth_apiv1.IntrospectRequest{Token: "access"}, auth.ErrPermissionDenied, codes.PermissionDenied},
+		{"storage failure", &auth_apiv1.IntrospectRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err, introspection: active}}
+		if _, err := s.Introspect(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.VerifyEmailRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.VerifyEmailRequest{Token: "token"}, nil, codes.OK},
+		{"no token", &auth_apiv1.VerifyEmailRequest{}, nil, codes.InvalidArgument},
+		{"used token", &auth_apiv1.VerifyEmailRequest{Token: "token"}, wrapped(auth.ErrInvalidToken), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.VerifyEmailRequest{Token: "token"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.VerifyEmail(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ResetPasswordRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.ResetPasswordRequest{Token: "token", NewPassword: "secret"}, nil, codes.OK},
+		{"no token", &auth_apiv1.ResetPasswordRequest{NewPassword: "secret"}, nil, codes.InvalidArgument},
+		{"no password", &auth_apiv1.ResetPasswordRequest{Token: "token"}, nil, codes.InvalidArgument},
+		{"used token", &auth_apiv1.ResetPasswordRequest{Token: "token", NewPassword: "secret"}, wrapped(auth.ErrInvalidToken), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.ResetPasswordRequest{Token: "token", NewPassword: "secret"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ResetPassword(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ChangePasswordRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, nil, codes.OK},
+		{"no token", &auth_apiv1.ChangePasswordRequest{OldPassword: "old", NewPassword: "new"}, nil, codes.Unauthenticated},
+		{"no old password", &auth_apiv1.ChangePasswordRequest{Token: "access", NewPassword: "new"}, nil, codes.InvalidArgument},
+		{"no new password", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"wrong password", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, wrapped(auth.ErrInvalidCredentials), codes.InvalidArgument},
+		{"throttled", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, wrapped(auth.ErrTooManyAttempts), codes.ResourceExhausted},
+		{"locked", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, wrapped(auth.ErrAccountLocked), codes.PermissionDenied},
+		{"storage failure", &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ChangePassword(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.ChangePassword(context.Background(), &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new", KeepSession: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetToken() != "access" || resp.GetRefreshToken() != "refresh" {
+		t.Errorf("response = %v, want the new token pair", resp)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.UpdateProfileRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.UpdateProfileRequest{Token: "access", Name: "User"}, nil, codes.OK},
+		{"no token", &auth_apiv1.UpdateProfileRequest{Name: "User"}, nil, codes.Unauthenticated},
+		{"no name", &auth_apiv1.UpdateProfileRequest{Token: "access"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.UpdateProfileRequest{Token: "access", Name: "User"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"reserved name", &auth_apiv1.UpdateProfileRequest{Token: "access", Name: "Admin"}, wrapped(&displayname.Violation{Err: displayname.ErrReserved}), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.UpdateProfileRequest{Token: "access", Name: "User"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.UpdateProfile(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ChangeEmailRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.ChangeEmailRequest{Token: "access", NewEmail: "new@example.com"}, nil, codes.OK},
+		{"no token", &auth_apiv1.ChangeEmailRequest{NewEmail: "new@example.com"}, nil, codes.Unauthenticated},
+		{"no email", &auth_apiv1.ChangeEmailRequest{Token: "access"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.ChangeEmailRequest{Token: "access", NewEmail: "new@example.com"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"taken email", &auth_apiv1.ChangeEmailRequest{Token: "access", NewEmail: "new@example.com"}, wrapped(storage.ErrUserExists), codes.InvalidArgument},
+		{"disposable email", &auth_apiv1.ChangeEmailRequest{Token: "access", NewEmail: "new@example.com"}, wrapped(emailaddr.ErrDisposable), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.ChangeEmailRequest{Token: "access", NewEmail: "new@example.com"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ChangeEmail(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ConfirmEmailChangeRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.ConfirmEmailChangeRequest{Token: "token"}, nil, codes.OK},
+		{"no token", &auth_apiv1.ConfirmEmailChangeRequest{}, nil, codes.InvalidArgument},
+		{"used token", &auth_apiv1.ConfirmEmailChangeRequest{Token: "token"}, wrapped(auth.ErrInvalidToken), codes.InvalidArgument},
+		{"taken email", &auth_apiv1.ConfirmEmailChangeRequest{Token: "token"}, wrapped(storage.ErrUserExists), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.ConfirmEmailChangeRequest{Token: "token"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ConfirmEmailChange(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.DeleteAccountRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, nil, codes.OK},
+		{"no token", &auth_apiv1.DeleteAccountRequest{Password: "secret"}, nil, codes.Unauthenticated},
+		{"no password", &auth_apiv1.DeleteAccountRequest{Token: "access"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"wrong password", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, wrapped(auth.ErrInvalidCredentials), codes.InvalidArgument},
+		{"throttled", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, wrapped(auth.ErrTooManyAttempts), codes.ResourceExhausted},
+		{"locked", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, wrapped(auth.ErrAccountLocked), codes.PermissionDenied},
+		{"storage failure", &auth_apiv1.DeleteAccountRequest{Token: "access", Password: "secret"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.DeleteAccount(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestExportMyData(t *testing.T) {
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.ExportMyData(context.Background(), &auth_apiv1.ExportMyDataRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.GetData()) != `{"user":{}}` {
+		t.Errorf("data = %s", resp.GetData())
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ExportMyDataRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.ExportMyDataRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.ExportMyDataRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"storage failure", &auth_apiv1.ExportMyDataRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ExportMyData(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.EnrollTOTP(context.Background(), &auth_apiv1.EnrollTOTPRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetSecret() != "JBSWY3DPEHPK3PXP" || resp.GetUri() == "" {
+		t.Errorf("response = %v", resp)
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.EnrollTOTPRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.EnrollTOTPRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.EnrollTOTPRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"already enabled", &auth_apiv1.EnrollTOTPRequest{Token: "access"}, wrapped(auth.ErrMFAAlreadyEnabled), codes.FailedPrecondition},
+		{"disabled", &auth_apiv1.EnrollTOTPRequest{Token: "access"}, wrapped(auth.ErrMFADisabled), codes.Unimplemented},
+		{"storage failure", &auth_apiv1.EnrollTOTPRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.EnrollTOTP(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.ConfirmTOTPRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, nil, codes.OK},
+		{"no token", &auth_apiv1.ConfirmTOTPRequest{Code: "123456"}, nil, codes.Unauthenticated},
+		{"no code", &auth_apiv1.ConfirmTOTPRequest{Token: "access"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"wrong code", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, wrapped(auth.ErrInvalidMFACode), codes.InvalidArgument},
+		{"not enrolled", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, wrapped(auth.ErrMFANotEnrolled), codes.FailedPrecondition},
+		{"already enabled", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, wrapped(auth.ErrMFAAlreadyEnabled), codes.FailedPrecondition},
+		{"disabled", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, wrapped(auth.ErrMFADisabled), codes.Unimplemented},
+		{"storage failure", &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.ConfirmTOTP(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.ConfirmTOTP(context.Background(), &auth_apiv1.ConfirmTOTPRequest{Token: "access", Code: "123456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetRecoveryCodes()) != 1 {
+		t.Errorf("recovery codes = %v, want 1", resp.GetRecoveryCodes())
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *auth_apiv1.VerifyMFARequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, nil, codes.OK},
+		{"no token", &auth_apiv1.VerifyMFARequest{Code: "123456"}, nil, codes.Unauthenticated},
+		{"no code", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa"}, nil, codes.InvalidArgument},
+		{"invalid token", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"wrong code", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, wrapped(auth.ErrInvalidMFACode), codes.InvalidArgument},
+		{"throttled", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, wrapped(&auth.ThrottledError{RetryAfter: time.Minute}), codes.ResourceExhausted},
+		{"disabled", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, wrapped(auth.ErrMFADisabled), codes.Unimplemented},
+		{"storage failure", &auth_apiv1.VerifyMFARequest{MfaToken: "mfa", Code: "123456"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.VerifyMFA(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.RegenerateRecoveryCodes(context.Background(), &auth_apiv1.RegenerateRecoveryCodesRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetRecoveryCodes()) != 2 {
+		t.Errorf("recovery codes = %v, want 2", resp.GetRecoveryCodes())
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.RegenerateRecoveryCodesRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.RegenerateRecoveryCodesRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.RegenerateRecoveryCodesRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"not enrolled", &auth_apiv1.RegenerateRecoveryCodesRequest{Token: "access"}, wrapped(auth.ErrMFANotEnrolled), codes.FailedPrecondition},
+		{"storage failure", &auth_apiv1.RegenerateRecoveryCodesRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.RegenerateRecoveryCodes(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestGetRecoveryCodesCount(t *testing.T) {
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.GetRecoveryCodesCount(context.Background(), &auth_apiv1.GetRecoveryCodesCountRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRemaining() != 7 {
+		t.Errorf("remaining = %d, want 7", resp.GetRemaining())
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.GetRecoveryCodesCountRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.GetRecoveryCodesCountRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.GetRecoveryCodesCountRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"storage failure", &auth_apiv1.GetRecoveryCodesCountRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.GetRecoveryCodesCount(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestBeginPasskeyRegistration(t *testing.T) {
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.BeginPasskeyRegistration(context.Background(), &auth_apiv1.BeginPasskeyRegistrationRequest{Token: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetOptionsJson() != `{"challenge":"Y2hhbGxlbmdl"}` {
+		t.Errorf("options = %s", resp.GetOptionsJson())
+	}
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.BeginPasskeyRegistrationRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", &auth_apiv1.BeginPasskeyRegistrationRequest{}, nil, codes.Unauthenticated},
+		{"invalid token", &auth_apiv1.BeginPasskeyRegistrationRequest{Token: "access"}, wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"storage failure", &auth_apiv1.BeginPasskeyRegistrationRequest{Token: "access"}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.BeginPasskeyRegistration(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestFinishPasskeyRegistration(t *testing.T) {
+	valid := func() *auth_apiv1.FinishPasskeyRegistrationRequest {
+		return &auth_apiv1.FinishPasskeyRegistrationRequest{Token: "access", ClientDataJson: []byte("{}"), AttestationObject: []byte{0xa0}}
+	}
+
+	s := &serverApi{auth: &fakeAuth{}}
+	resp, err := s.FinishPasskeyRegistration(context.Background(), valid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetCredentialId() != "-_8" {
+		t.Errorf("credential id = %q, want base64url without padding", resp.GetCredentialId())
+	}
+
+	noToken, noAttestation := valid(), valid()
+	noToken.Token = ""
+	noAttestation.AttestationObject = nil
+	tests := []struct {
+		name string
+		req  *auth_apiv1.FinishPasskeyRegistrationRequest
+		err  error
+		want codes.Code
+	}{
+		{"no token", noToken, nil, codes.Unauthenticated},
+		{"no attestation", noAttestation, nil, codes.InvalidArgument},
+		{"invalid token", valid(), wrapped(auth.ErrInvalidToken), codes.Unauthenticated},
+		{"invalid passkey", valid(), wrapped(auth.ErrInvalidPasskey), codes.InvalidArgument},
+		{"known passkey", valid(), wrapped(auth.ErrPasskeyExists), codes.AlreadyExists},
+		{"storage failure", valid(), errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.FinishPasskeyRegistration(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestFinishPasskeyLogin(t *testing.T) {
+	valid := func() *auth_apiv1.FinishPasskeyLoginRequest {
+		return &auth_apiv1.FinishPasskeyLoginRequest{
+			CredentialId:      []byte{1},
+			ClientDataJson:    []byte("{}"),
+			AuthenticatorData: []byte{2},
+			Signature:         []byte{3},
+		}
+	}
+	noSignature := valid()
+	noSignature.Signature = nil
+
+	tests := []struct {
+		name string
+		req  *auth_apiv1.FinishPasskeyLoginRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", valid(), nil, codes.OK},
+		{"no signature", noSignature, nil, codes.InvalidArgument},
+		{"invalid passkey", valid(), wrapped(auth.ErrInvalidPasskey), codes.Unauthenticated},
+		{"unknown audience", valid(), wrapped(auth.ErrInvalidAudience), codes.InvalidArgument},
+		{"storage failure", valid(), errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.FinishPasskeyLogin(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"rounded", wrapped(&auth.ThrottledError{RetryAfter: 90*time.Second + 400*time.Millisecond}), 90 * time.Second},
+		{"at least a second", wrapped(&auth.ThrottledError{RetryAfter: 100 * time.Millisecond}), time.Second},
+		{"without a wait", wrapped(auth.ErrTooManyAttempts), 0},
+	}
+	for _, tt := range tests {
+		st := status.Convert(throttled(context.Background(), tt.err))
+		if st.Code() != codes.ResourceExhausted {
+			t.Errorf("%s: code = %v, want ResourceExhausted", tt.name, st.Code())
+		}
+		var got time.Duration
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				got = info.GetRetryDelay().AsDuration()
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: retry delay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLockUser(t *testing.T) {
+	const userID = "0b6a1f0e-8d5c-4f43-9a57-3c3a4c1d2e5f"
+	tests := []struct {
+		name string
+		req  *auth_apiv1.LockUserRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: userID}, nil, codes.OK},
+		{"invalid user id", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: "42"}, nil, codes.InvalidArgument},
+		{"wrong key", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: userID}, wrapped(auth.ErrPermissionDenied), codes.PermissionDenied},
+		{"unknown user", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: userID}, wrapped(storage.ErrUserNotFound), codes.NotFound},
+		{"until in the past", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: userID}, wrapped(auth.ErrInvalidLockUntil), codes.InvalidArgument},
+		{"storage failure", &auth_apiv1.LockUserRequest{AdminKey: "key", UserId: userID}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.LockUser(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	const userID = "0b6a1f0e-8d5c-4f43-9a57-3c3a4c1d2e5f"
+	tests := []struct {
+		name string
+		req  *auth_apiv1.UnlockUserRequest
+		err  error
+		want codes.Code
+	}{
+		{"ok", &auth_apiv1.UnlockUserRequest{AdminKey: "key", UserId: userID}, nil, codes.OK},
+		{"invalid user id", &auth_apiv1.UnlockUserRequest{AdminKey: "key", UserId: "42"}, nil, codes.InvalidArgument},
+		{"wrong key", &auth_apiv1.UnlockUserRequest{AdminKey: "key", UserId: userID}, wrapped(auth.ErrPermissionDenied), codes.PermissionDenied},
+		{"unknown user", &auth_apiv1.UnlockUserRequest{AdminKey: "key", UserId: userID}, wrapped(storage.ErrUserNotFound), codes.NotFound},
+		{"storage failure", &auth_apiv1.UnlockUserRequest{AdminKey: "key", UserId: userID}, errors.New("connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		s := &serverApi{auth: &fakeAuth{err: tt.err}}
+		if _, err := s.UnlockUser(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, status.Code(err), tt.want)
+		}
+	}
+}
+
+func TestAccountLocked(t *testing.T) {
+	until := time.Date(2030, time.March, 5, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"temporary", wrapped(&auth.AccountLockedError{Until: until}), "Учетная запись заблокирована до 05.03.2030 14:30 UTC"},
+		{"permanent", wrapped(&auth.AccountLockedError{Until: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)}), "Учетная запись заблокирована"},
+		{"bare", wrapped(auth.ErrAccountLocked), "Учетная запись заблокирована"},
+	}
+	for _, tt := range tests {
+		st := status.Convert(accountLocked(tt.err))
+		if st.Code() != codes.PermissionDenied || st.Message() != tt.want {
+			t.Errorf("%s: status = %v %q, want PermissionDenied %q", tt.name, st.Code(), st.Message(), tt.want)
+		}
+	}
+}
+
+func TestPasswordViolation(t *testing.T) {
+	tests := []struct {
+		violation *password.Violation
+		want      string
+	}{
+		{&password.Violation{Err: password.ErrTooShort, Limit: 12}, "password: Пароль должен содержать не менее 12 символов"},
+		{&password.Violation{Err: password.ErrTooLong, Limit: 64}, "password: Пароль должен содержать не более 64 символов"},
+		{&password.Violation{Err: password.ErrCommon}, "password: Этот пароль слишком распространен"},
+		{&password.Violation{Err: password.ErrBreached}, "password: Этот пароль встречается в утечках данных, выберите другой"},
+		{&password.Violation{Err: password.ErrTooWeak, Limit: 3}, "password: Пароль слишком простой"},
+	}
+	for _, tt := range tests {
+		st := status.Convert(passwordViolation(tt.violation))
+		if st.Code() != codes.InvalidArgument || st.Message() != tt.want {
+			t.Errorf("%v: status = %v %q, want InvalidArgument %q", tt.violation, st.Code(), st.Message(), tt.want)
+		}
+	}
+
+	violation := wrapped(&password.Violation{Err: password.ErrMissingDigit})
+	for name, call := range map[string]func(s *serverApi) error{
+		"Register": func(s *serverApi) error {
+			_, err := s.Register(context.Background(), &auth_apiv1.RegisterRequest{Name: "User", Email: "user@example.com", Password: "secret"})
+			return err
+		},
+		"ResetPassword": func(s *serverApi) error {
+			_, err := s.ResetPassword(context.Background(), &auth_apiv1.ResetPasswordRequest{Token: "token", NewPassword: "secret"})
+			return err
+		},
+		"ChangePassword": func(s *serverApi) error {
+			_, err := s.ChangePassword(context.Background(), &auth_apiv1.ChangePasswordRequest{Token: "access", OldPassword: "old", NewPassword: "new"})
+			return err
+		},
+	} {
+		st := status.Convert(call(&serverApi{auth: &fakeAuth{err: violation}}))
+		if st.Code() != codes.InvalidArgument || st.Message() != "password: Пароль должен содержать цифру" {
+			t.Errorf("%s: status = %v %q", name, st.Code(), st.Message())
+		}
+	}
+}
+
+func TestNameViolation(t *testing.T) {
+	tests := []struct {
+		violation *displayname.Violation
+		want      string
+	}{
+		{&displayname.Violation{Err: displayname.ErrTooShort, Limit: 2}, "name: Имя должно содержать не менее 2 символов"},
+		{&displayname.Violation{Err: displayname.ErrTooLong, Limit: 64}, "name: Имя должно содержать не более 64 символов"},
+		{&displayname.Violation{Err: displayname.ErrReserved}, "name: Это имя зарезервировано"},
+		{&displayname.Violation{Err: displayname.ErrInvalidCharacter}, "name: Имя содержит недопустимые символы"},
+	}
+	for _, tt := range tests {
+		st := status.Convert(nameViolation(tt.violation))
+		if st.Code() != codes.InvalidArgument || st.Message() != tt.want {
+			t.Errorf("%v: status = %v %q, want InvalidArgument %q", tt.violation, st.Code(), st.Message(), tt.want)
+		}
+	}
+}
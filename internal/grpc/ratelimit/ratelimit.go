@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"auth-api/internal/lib/clientinfo"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	KeyIP      = "ip"
+	KeySubject = "subject"
+
+	pruneInterval     = time.Minute
+	defaultMaxBuckets = 100_000
+)
+
+// Budget is a token bucket refilled with Rate tokens per second and holding
+// at most Burst tokens. Both must be positive. Key picks whom the bucket
+// belongs to: the client address or the subject of the access token sent
+// with the request, falling back to the address for anonymous calls.
+type Budget struct {
+	Rate  float64
+	Burst int
+	Key   string
+}
+
+// SubjectFunc returns the user id of a valid access token.
+type SubjectFunc func(ctx context.Context, token string) (string, bool)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	budget Budget
+}
+
+// Limiter keeps one bucket per method and key. Methods are looked up by full
+// name ("/package.Service/Method") and then by bare method name; methods
+// without a budget use the default one, if set. At most maxBuckets buckets
+// are kept, a zero maxBuckets picks a default. While all of them are still
+// draining new keys are rejected, so a flood of distinct clients cannot
+// reset the bucket of an exhausted one.
+type Limiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	budgets    map[string]Budget
+	def        *Budget
+	subject    SubjectFunc
+	maxBuckets int
+	lastPrune  time.Time
+	// nextRefill is the earliest a kept bucket may have refilled, before it
+	// pruning a full limiter cannot free anything.
+	nextRefill time.Time
+}
+
+func New(budgets map[string]Budget, def *Budget, subject SubjectFunc, maxBuckets int) *Limiter {
+	if maxBuckets <= 0 {
+		maxBuckets = defaultMaxBuckets
+	}
+	return &Limiter{
+		buckets:    make(map[string]*bucket),
+		budgets:    budgets,
+		def:        def,
+		subject:    subject,
+		maxBuckets: maxBuckets,
+		lastPrune:  time.Now(),
+	}
+}
+
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		budget, ok := l.budget(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		key := l.key(ctx, req, budget)
+		if wait, ok := l.take(info.FullMethod+"|"+key, budget, time.Now()); !ok {
+			return nil, exhausted(ctx, wait)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (l *Limiter) budget(fullMethod string) (Budget, bool) {
+	if budget, ok := l.budgets[fullMethod]; ok {
+		return budget, true
+	}
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		if budget, ok := l.budgets[fullMethod[i+1:]]; ok {
+			return budget, true
+		}
+	}
+	if l.def != nil {
+		return *l.def, true
+	}
+	return Budget{}, false
+}
+
+func (l *Limiter) key(ctx context.Context, req any, budget Budget) string {
+	if budget.Key == KeySubject && l.subject != nil {
+		if token := requestToken(ctx, req); token != "" {
+			if subject, ok := l.subject(ctx, token); ok {
+				return "sub:" + subject
+			}
+		}
+	}
+	// The address was resolved by the clientinfo interceptor, forwarding
+	// headers only count when they come from a trusted proxy.
+	return "ip:" + clientinfo.FromContext(ctx).IP
+}
+
+// take spends a token from the bucket, or reports how long until one is
+// available.
+func (l *Limiter) take(key string, budget Budget, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > pruneInterval {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxBuckets && !now.Before(l.nextRefill) {
+			l.prune(now)
+		}
+		if len(l.buckets) >= l.maxBuckets {
+			return l.nextRefill.Sub(now), false
+		}
+		b = &bucket{tokens: float64(budget.Burst), last: now, budget: budget}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(float64(budget.Burst), b.tokens+now.Sub(b.last).Seconds()*budget.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / budget.Rate * float64(time.Second)), false
+}
+
+// prune drops buckets that have refilled completely, they are equivalent to
+// new ones.
+func (l *Limiter) prune(now time.Time) {
+	l.nextRefill = time.Time{}
+	for key, b := range l.buckets {
+		refilled := b.last.Add(time.Duration(float64(b.budget.Burst) / b.budget.Rate * float64(time.Second)))
+		if now.After(refilled) {
+			delete(l.buckets, key)
+			continue
+		}
+		if l.nextRefill.IsZero() || refilled.Before(l.nextRefill) {
+			l.nextRefill = refilled
+		}
+	}
+	l.lastPrune = now
+}
+
+// requestToken finds the access token either in the request message or in
+// the authorization metadata.
+func requestToken(ctx context.Context, req any) string {
+	if r, ok := req.(interface{ GetToken() string }); ok && r.GetToken() != "" {
+		return r.GetToken()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			return strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+	return ""
+}
+
+func exhausted(ctx context.Context, wait time.Duration) error {
+	wait = max(wait.Round(time.Second), time.Second)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(wait.Seconds()))))
+
+	st, err := status.New(codes.ResourceExhausted, "Слишком много запросов, попробуйте позже").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "Слишком много запросов, попробуйте позже")
+	}
+	return st.Err()
+}
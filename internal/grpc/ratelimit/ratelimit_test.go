@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTake(t *testing.T) {
+	l := New(nil, nil, nil, 0)
+	budget := Budget{Rate: 1, Burst: 2, Key: KeyIP}
+	now := time.Unix(1000, 0)
+
+	steps := []struct {
+		after    time.Duration
+		wantOK   bool
+		wantWait time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, time.Second},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		{10 * time.Second, true, 0},
+		{0, true, 0},
+		{0, false, time.Second},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		wait, ok := l.take("/auth.AuthAPI/Login|ip:1.2.3.4", budget, now)
+		if ok != step.wantOK || wait != step.wantWait {
+			t.Fatalf("step %d: take = %v, %v; want %v, %v", i, wait, ok, step.wantWait, step.wantOK)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := New(nil, nil, nil, 0)
+	budget := Budget{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	l.take("/auth.AuthAPI/Login|ip:1.1.1.1", budget, now)
+	l.take("/auth.AuthAPI/Login|ip:2.2.2.2", budget, now.Add(pruneInterval+time.Second))
+	l.take("/auth.AuthAPI/Login|ip:2.2.2.2", budget, now.Add(pruneInterval+2*time.Second))
+
+	// The first bucket has refilled and is dropped, the busy one is kept.
+	if _, ok := l.buckets["/auth.AuthAPI/Login|ip:1.1.1.1"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := l.buckets["/auth.AuthAPI/Login|ip:2.2.2.2"]; !ok {
+		t.Error("active bucket was dropped")
+	}
+}
+
+func TestMaxBuckets(t *testing.T) {
+	l := New(nil, nil, nil, 2)
+	budget := Budget{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+		for range 2 {
+			l.take("/auth.AuthAPI/Login|ip:"+ip, budget, now.Add(time.Duration(i)*time.Millisecond))
+		}
+	}
+
+	// Both buckets are exhausted: a new client is refused instead of
+	// pushing one of them out.
+	wait, ok := l.take("/auth.AuthAPI/Login|ip:3.3.3.3", budget, now.Add(2*time.Millisecond))
+	if ok || wait <= 0 {
+		t.Errorf("take of a new key while full = %v, %v; want a wait", wait, ok)
+	}
+	if len(l.buckets) != 2 {
+		t.Errorf("len(buckets) = %d, want 2", len(l.buckets))
+	}
+	if _, ok := l.take("/auth.AuthAPI/Login|ip:1.1.1.1", budget, now.Add(3*time.Millisecond)); ok {
+		t.Error("exhausted bucket was forgotten")
+	}
+
+	// Once a bucket has refilled it makes room for the new client.
+	if _, ok := l.take("/auth.AuthAPI/Login|ip:3.3.3.3", budget, now.Add(3*time.Second)); !ok {
+		t.Error("new key refused after a bucket refilled")
+	}
+	if len(l.buckets) > 2 {
+		t.Errorf("len(buckets) = %d, want at most 2", len(l.buckets))
+	}
+}
+
+func TestBudgetLookup(t *testing.T) {
+	login := Budget{Rate: 1, Burst: 1}
+	full := Budget{Rate: 2, Burst: 2}
+	def := Budget{Rate: 3, Burst: 3}
+	l := New(map[string]Budget{"Login": login, "/auth.AuthAPI/Refresh": full}, &def, nil, 0)
+
+	tests := []struct {
+		method string
+		want   Budget
+	}{
+		{"/auth.AuthAPI/Login", login},
+		{"/auth.AuthAPI/Refresh", full},
+		{"/auth.AuthAPI/GetUser", def},
+	}
+	for _, tt := range tests {
+		if got, ok := l.budget(tt.method); !ok || got != tt.want {
+			t.Errorf("budget(%q) = %v, %v; want %v", tt.method, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := New(nil, nil, nil, 0).budget("/auth.AuthAPI/Login"); ok {
+		t.Error("budget found without budgets or a default")
+	}
+}
+
+// tokenRequest stands in for request messages carrying an access token.
+type tokenRequest struct{ token string }
+
+func (r tokenRequest) GetToken() string { return r.token }
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	budgets := map[string]Budget{
+		"Login":   {Rate: 0.001, Burst: 1, Key: KeyIP},
+		"GetUser": {Rate: 0.001, Burst: 1, Key: KeySubject},
+	}
+	subject := func(ctx context.Context, token string) (string, bool) {
+		user, ok := strings.CutPrefix(token, "valid-")
+		return user, ok
+	}
+	interceptor := New(budgets, nil, subject, 0).UnaryServerInterceptor()
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	call := func(method string, req any, md metadata.MD) error {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/auth.AuthAPI/" + method}, handler)
+		return err
+	}
+
+	if err := call("Login", nil, nil); err != nil {
+		t.Fatalf("first Login: %v", err)
+	}
+	err := call("Login", nil, nil)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second Login: code = %v, want ResourceExhausted", status.Code(err))
+	}
+	var delay time.Duration
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			delay = info.GetRetryDelay().AsDuration()
+		}
+	}
+	if delay < time.Second {
+		t.Errorf("retry delay = %v, want at least a second", delay)
+	}
+
+	// Methods without a budget are not limited.
+	for range 3 {
+		if err := call("Refresh", nil, nil); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+	}
+
+	// Subject budgets follow the token owner, from the message or metadata.
+	if err := call("GetUser", tokenRequest{"valid-alice"}, nil); err != nil {
+		t.Fatalf("GetUser of alice: %v", err)
+	}
+	if err := call("GetUser", nil, metadata.Pairs("authorization", "Bearer valid-alice")); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second GetUser of alice: code = %v, want ResourceExhausted", status.Code(err))
+	}
+	if err := call("GetUser", tokenRequest{"valid-bob"}, nil); err != nil {
+		t.Errorf("GetUser of bob: %v", err)
+	}
+	// Invalid tokens fall back to the address bucket.
+	if err := call("GetUser", tokenRequest{"forged"}, nil); err != nil {
+		t.Errorf("GetUser with an invalid token: %v", err)
+	}
+	if err := call("GetUser", tokenRequest{"forged-again"}, nil); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second GetUser with an invalid token: code = %v, want ResourceExhausted", status.Code(err))
+	}
+}
@@ -0,0 +1,20 @@
+package wellknown
+
+import (
+	"auth-api/internal/lib/jwt"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type KeyProvider interface {
+	JWKS(ctx context.Context) jwt.JSONWebKeySet
+}
+
+func Register(mux *http.ServeMux, keys KeyProvider) {
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(keys.JWKS(r.Context()))
+	})
+}
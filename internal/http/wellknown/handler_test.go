@@ -0,0 +1,44 @@
+package wellknown
+
+import (
+	"auth-api/internal/lib/jwt"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type staticKeys jwt.JSONWebKeySet
+
+func (k staticKeys) JWKS(ctx context.Context) jwt.JSONWebKeySet {
+	return jwt.JSONWebKeySet(k)
+}
+
+func TestJWKS(t *testing.T) {
+	keys := staticKeys{Keys: []jwt.JSONWebKey{{Kty: "OKP", Use: "sig", Alg: jwt.AlgEdDSA, Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}}}
+	mux := http.NewServeMux()
+	Register(mux, keys)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var set jwt.JSONWebKeySet
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0] != keys.Keys[0] {
+		t.Errorf("keys = %+v, want %+v", set.Keys, keys.Keys)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,155 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DriverHTTP = "http"
+	DriverFile = "file"
+)
+
+// prefixLength is how many hex characters of the SHA-1 hash leave the
+// process in a range query.
+const prefixLength = 5
+
+// RangeClient looks passwords up with the k-anonymity range protocol: only
+// the first five characters of the SHA-1 hash are sent and the endpoint
+// answers with every known suffix, one "SUFFIX:COUNT" per line.
+type RangeClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewRangeClient takes a format string receiving the hash prefix, e.g.
+// "https://api.pwnedpasswords.com/range/%s".
+func NewRangeClient(url string, timeout time.Duration) *RangeClient {
+	return &RangeClient{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *RangeClient) Breached(ctx context.Context, password string) (bool, error) {
+	hash := hashPassword(password)
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.url, prefix), nil)
+	if err != nil {
+		return false, fmt.Errorf("build range request: %w", err)
+	}
+	// Padding hides the real number of suffixes from anyone watching the
+	// response size; padded entries have a zero count.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("range request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("range request: unexpected status %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read range response: %w", err)
+	}
+	return false, nil
+}
+
+// FileChecker searches a local list of SHA-1 hashes sorted in ascending
+// order, one per line, optionally followed by ":COUNT" as in the downloadable
+// breach corpora. The file is binary searched in place, so it is never
+// loaded into memory.
+type FileChecker struct {
+	file *os.File
+	size int64
+}
+
+func NewFileChecker(path string) (*FileChecker, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach list: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat breach list: %w", err)
+	}
+	return &FileChecker{file: file, size: info.Size()}, nil
+}
+
+func (c *FileChecker) Breached(ctx context.Context, password string) (bool, error) {
+	hash := hashPassword(password)
+
+	lo, hi := int64(0), c.size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		mid := lo + (hi-lo)/2
+		line, next, err := c.lineFrom(mid)
+		if errors.Is(err, io.EOF) {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("read breach list: %w", err)
+		}
+
+		candidate, _, _ := strings.Cut(line, ":")
+		switch strings.Compare(strings.ToUpper(candidate), hash) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = next
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+func (c *FileChecker) Close() error {
+	return c.file.Close()
+}
+
+// lineFrom returns the first line starting at or after off together with
+// the offset right behind it, io.EOF when there is none.
+func (c *FileChecker) lineFrom(off int64) (string, int64, error) {
+	start := max(off-1, 0)
+	r := bufio.NewReaderSize(io.NewSectionReader(c.file, start, c.size-start), 128)
+	if off > 0 {
+		// The byte before off tells whether off already starts a line.
+		skipped, err := r.ReadString('\n')
+		if err != nil {
+			return "", 0, err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", 0, err
+	}
+	return strings.TrimSpace(line), start + int64(len(line)), nil
+}
+
+func hashPassword(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,119 @@
+package breach
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRangeClient(t *testing.T) {
+	breached := hashPassword("password")
+	padded := hashPassword("padded")
+	var prefixes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		prefixes = append(prefixes, prefix)
+		if r.Header.Get("Add-Padding") != "true" {
+			t.Error("Add-Padding header is not set")
+		}
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:3\r\n")
+		if strings.HasPrefix(breached, prefix) {
+			fmt.Fprintf(w, "%s:9545824\r\n", strings.ToLower(breached[prefixLength:]))
+		}
+		if strings.HasPrefix(padded, prefix) {
+			fmt.Fprintf(w, "%s:0\r\n", padded[prefixLength:])
+		}
+	}))
+	defer server.Close()
+
+	client := NewRangeClient(server.URL+"/range/%s", time.Second)
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"padded", false},
+		{"vq8#Lm2!zR7&", false},
+	}
+	for _, tt := range tests {
+		got, err := client.Breached(context.Background(), tt.password)
+		if err != nil {
+			t.Fatalf("Breached(%q): %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("Breached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+	for _, prefix := range prefixes {
+		if len(prefix) != prefixLength {
+			t.Errorf("sent %q, want a %d character prefix", prefix, prefixLength)
+		}
+	}
+}
+
+func TestRangeClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := NewRangeClient(server.URL+"/%s", time.Second).Breached(context.Background(), "password"); err == nil {
+		t.Error("Breached with a failing endpoint: err = nil")
+	}
+}
+
+func TestFileChecker(t *testing.T) {
+	breached := []string{"password", "123456", "qwerty", "letmein", "dragon", "monkey", "iloveyou"}
+	for i := range 500 {
+		breached = append(breached, fmt.Sprintf("leaked-%d", i))
+	}
+	lines := make([]string, 0, len(breached))
+	for i, password := range breached {
+		lines = append(lines, fmt.Sprintf("%s:%d", hashPassword(password), i+1))
+	}
+	slices.Sort(lines)
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := NewFileChecker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+
+	for _, password := range breached {
+		if got, err := checker.Breached(context.Background(), password); err != nil || !got {
+			t.Errorf("Breached(%q) = %v, %v, want true", password, got, err)
+		}
+	}
+	for _, password := range []string{"", "Password", "leaked-500", "vq8#Lm2!zR7&", "correct horse battery staple"} {
+		if got, err := checker.Breached(context.Background(), password); err != nil || got {
+			t.Errorf("Breached(%q) = %v, %v, want false", password, got, err)
+		}
+	}
+}
+
+func TestFileCheckerEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewFileChecker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+
+	if got, err := checker.Breached(context.Background(), "password"); err != nil || got {
+		t.Errorf("Breached on an empty list = %v, %v, want false", got, err)
+	}
+}
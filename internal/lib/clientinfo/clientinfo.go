@@ -0,0 +1,198 @@
+package clientinfo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type Info struct {
+	UserAgent  string
+	IP         string
+	DeviceName string
+}
+
+// Proxies lists the networks of reverse proxies whose forwarding headers are
+// believed.
+type Proxies []netip.Prefix
+
+// ParseProxies accepts CIDR ranges and single addresses.
+func ParseProxies(values []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(values))
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+func (p Proxies) trusted(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type ipKey struct{}
+
+// UnaryServerInterceptor resolves the client address once per call. Forwarding
+// headers are only read when the peer is a trusted proxy, and then the
+// right-most untrusted hop of x-forwarded-for is taken: everything left of it
+// was written by the client and can be forged. It has to run before anything
+// calling FromContext.
+func UnaryServerInterceptor(proxies Proxies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(context.WithValue(ctx, ipKey{}, proxies.clientIP(ctx)), req)
+	}
+}
+
+func (p Proxies) clientIP(ctx context.Context) string {
+	addr, ok := peerAddr(ctx)
+	if !ok {
+		return ""
+	}
+	if !p.trusted(addr) {
+		return addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get("x-forwarded-for")
+	if len(forwarded) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(first(md, "x-real-ip"))); err == nil {
+			return realIP.Unmap().String()
+		}
+		return addr.String()
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry cannot be followed further, the last
+			// trusted hop is the best we know.
+			break
+		}
+		addr = hop.Unmap()
+		if !p.trusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func peerAddr(ctx context.Context) (netip.Addr, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}, false
+	}
+	host := p.Addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// FromContext collects client details of an incoming gRPC call. The address
+// is the one resolved by UnaryServerInterceptor, or the peer address when the
+// interceptor did not run.
+func FromContext(ctx context.Context) Info {
+	var info Info
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		info.UserAgent = first(md, "x-user-agent", "user-agent")
+		info.DeviceName = first(md, "x-device-name")
+	}
+
+	if ip, ok := ctx.Value(ipKey{}).(string); ok {
+		info.IP = ip
+	} else if addr, ok := peerAddr(ctx); ok {
+		info.IP = addr.String()
+	}
+
+	if info.DeviceName == "" {
+		info.DeviceName = DeviceName(info.UserAgent)
+	}
+
+	return info
+}
+
+// DeviceName turns a user agent into a short label like "Chrome on Windows".
+func DeviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := match(ua, []pair{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"yabrowser", "Yandex Browser"},
+		{"firefox", "Firefox"},
+		{"chrome", "Chrome"},
+		{"safari", "Safari"},
+		{"grpc-", "gRPC client"},
+		{"okhttp", "Android app"},
+		{"cfnetwork", "iOS app"},
+	})
+	os := match(ua, []pair{
+		{"android", "Android"},
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"windows", "Windows"},
+		{"mac os", "macOS"},
+		{"linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	return "Unknown device"
+}
+
+type pair struct {
+	needle string
+	label  string
+}
+
+func match(ua string, pairs []pair) string {
+	for _, p := range pairs {
+		if strings.Contains(ua, p.needle) {
+			return p.label
+		}
+	}
+	return ""
+}
+
+func first(md metadata.MD, keys ...string) string {
+	for _, key := range keys {
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package clientinfo
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want Info
+	}{
+		{"peer only", nil, Info{IP: "203.0.113.7", DeviceName: "Unknown device"}},
+		{"user agent", metadata.Pairs("user-agent", "grpc-go/1.71.0"),
+			Info{UserAgent: "grpc-go/1.71.0", IP: "203.0.113.7", DeviceName: "gRPC client"}},
+		{"forwarded user agent wins", metadata.Pairs("user-agent", "grpc-go/1.71.0", "x-user-agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0"),
+			Info{UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0", IP: "203.0.113.7", DeviceName: "Firefox on Linux"}},
+		{"device name header", metadata.Pairs("x-device-name", "Work laptop"),
+			Info{IP: "203.0.113.7", DeviceName: "Work laptop"}},
+		{"forwarded for ignored", metadata.Pairs("x-forwarded-for", "198.51.100.1, 10.0.0.1"),
+			Info{IP: "203.0.113.7", DeviceName: "Unknown device"}},
+		{"real ip ignored", metadata.Pairs("x-real-ip", "198.51.100.2"),
+			Info{IP: "203.0.113.7", DeviceName: "Unknown device"}},
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", "203.0.113.7:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+		if tt.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+		}
+		if got := FromContext(ctx); got != tt.want {
+			t.Errorf("%s: FromContext = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		peer string
+		md   metadata.MD
+		want string
+	}{
+		{"untrusted peer", "203.0.113.7:5000", metadata.Pairs("x-forwarded-for", "198.51.100.1"), "203.0.113.7"},
+		{"untrusted peer real ip", "203.0.113.7:5000", metadata.Pairs("x-real-ip", "198.51.100.1"), "203.0.113.7"},
+		{"trusted peer", "10.0.0.2:5000", metadata.Pairs("x-forwarded-for", "198.51.100.1"), "198.51.100.1"},
+		{"right-most untrusted hop", "10.0.0.2:5000", metadata.Pairs("x-forwarded-for", "1.2.3.4, 198.51.100.1, 192.0.2.1"), "198.51.100.1"},
+		{"multiple headers", "10.0.0.2:5000", metadata.Pairs("x-forwarded-for", "1.2.3.4", "x-forwarded-for", "198.51.100.1"), "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5000", metadata.Pairs("x-forwarded-for", "10.0.0.3, 192.0.2.1"), "10.0.0.3"},
+		{"malformed hop", "10.0.0.2:5000", metadata.Pairs("x-forwarded-for", "198.51.100.1, garbage, 10.0.0.3"), "10.0.0.3"},
+		{"trusted peer real ip", "10.0.0.2:5000", metadata.Pairs("x-real-ip", "198.51.100.2"), "198.51.100.2"},
+		{"trusted peer no headers", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"ipv6 peer", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		addr, err := net.ResolveTCPAddr("tcp", tt.peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+		if tt.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+		}
+
+		var got string
+		handler := func(ctx context.Context, req any) (any, error) {
+			got = FromContext(ctx).IP
+			return nil, nil
+		}
+		if _, err := UnaryServerInterceptor(proxies)(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: IP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	if _, err := ParseProxies([]string{"10.0.0.0/8", "::1", "fd00::/8"}); err != nil {
+		t.Errorf("ParseProxies: %v", err)
+	}
+	if _, err := ParseProxies([]string{"proxy.local"}); err == nil {
+		t.Error("ParseProxies accepted a host name")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", "Unknown device"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36", "Chrome on Windows"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0", "Edge on Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15", "Safari on macOS"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile Safari/604.1", "Safari on iOS"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) YaBrowser/24.4 Chrome/122.0 Mobile Safari/537.36", "Yandex Browser on Android"},
+		{"okhttp/4.12.0", "Android app"},
+		{"Mozilla/5.0 (X11; Linux x86_64)", "Linux"},
+		{"curl/8.5.0", "Unknown device"},
+	}
+	for _, tt := range tests {
+		if got := DeviceName(tt.userAgent); got != tt.want {
+			t.Errorf("DeviceName(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package displayname
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrTooShort         = errors.New("name is too short")
+	ErrTooLong          = errors.New("name is too long")
+	ErrInvalidCharacter = errors.New("name contains invalid characters")
+	ErrReserved         = errors.New("name is reserved")
+)
+
+// Violation is returned by Policy.Normalize. Limit carries the length bound
+// that was violated for building a message.
+type Violation struct {
+	Err   error
+	Limit int
+}
+
+func (v *Violation) Error() string {
+	return v.Err.Error()
+}
+
+func (v *Violation) Unwrap() error {
+	return v.Err
+}
+
+// lookalikes folds letters that render like ASCII ones, so "аdmin" with a
+// Cyrillic "а" or "adm1n" still match a reserved "admin".
+var lookalikes = strings.NewReplacer(
+	// Cyrillic
+	"а", "a", "в", "b", "е", "e", "ё", "e", "к", "k", "м", "m", "н", "h",
+	"о", "o", "р", "p", "с", "c", "т", "t", "у", "y", "х", "x", "і", "i",
+	"ј", "j", "ѕ", "s", "ԁ", "d", "ӏ", "i",
+	// Greek
+	"α", "a", "β", "b", "ε", "e", "ι", "i", "κ", "k", "ν", "v", "ο", "o",
+	"ρ", "p", "τ", "t", "υ", "u", "χ", "x",
+	// digits and look-alike Latin letters
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "l", "i",
+)
+
+// Policy describes acceptable display names. Lengths are counted in
+// characters after normalization.
+type Policy struct {
+	minLength int
+	maxLength int
+	reserved  map[string]bool
+}
+
+func New(minLength int, maxLength int, reserved []string) *Policy {
+	skeletons := make(map[string]bool, len(reserved))
+	for _, word := range reserved {
+		if s := skeleton(word); s != "" {
+			skeletons[s] = true
+		}
+	}
+	return &Policy{minLength: max(minLength, 1), maxLength: maxLength, reserved: skeletons}
+}
+
+// Normalize returns the name in NFC with invisible format characters such as
+// zero-width spaces and bidi overrides removed and runs of whitespace
+// collapsed into one space. Control characters are rejected.
+func (p *Policy) Normalize(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", &Violation{Err: ErrInvalidCharacter}
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFC.String(name) {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+		case unicode.Is(unicode.Cf, r):
+		case unicode.IsControl(r), unicode.Is(unicode.Co, r), r == utf8.RuneError:
+			return "", &Violation{Err: ErrInvalidCharacter}
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+
+	length := utf8.RuneCountInString(normalized)
+	if length < p.minLength {
+		return "", &Violation{Err: ErrTooShort, Limit: p.minLength}
+	}
+	if p.maxLength > 0 && length > p.maxLength {
+		return "", &Violation{Err: ErrTooLong, Limit: p.maxLength}
+	}
+	if p.reserved[skeleton(normalized)] {
+		return "", &Violation{Err: ErrReserved}
+	}
+	return normalized, nil
+}
+
+// skeleton reduces a name to lowercase letters and digits with compatibility
+// forms and lookalikes folded, for comparing against reserved words.
+func skeleton(name string) string {
+	folded := lookalikes.Replace(strings.ToLower(norm.NFKC.String(name)))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, norm.NFKD.String(folded))
+}
@@ -0,0 +1,69 @@
+package displayname
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	p := New(2, 32, []string{"admin", "Support Team"})
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Иван Петров", "Иван Петров"},
+		{"  Ivan \t  Petrov  ", "Ivan Petrov"},
+		{"Ivan\nPetrov", "Ivan Petrov"},
+		{"Iv\u200ban\u200d", "Ivan"},
+		{"\u202eIvan", "Ivan"},
+		{"Jose\u0301", "Jos\u00e9"},
+		{"O'Brien-Smith", "O'Brien-Smith"},
+		{"Administrator", "Administrator"},
+	}
+	for _, tt := range tests {
+		got, err := p.Normalize(tt.name)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeViolations(t *testing.T) {
+	p := New(2, 32, []string{"admin", "Support Team"})
+	tests := []struct {
+		name  string
+		want  error
+		limit int
+	}{
+		{"I", ErrTooShort, 2},
+		{"\u200b\u200bI\u200b", ErrTooShort, 2},
+		{"   ", ErrTooShort, 2},
+		{strings.Repeat("я", 33), ErrTooLong, 32},
+		{"Ivan\x00", ErrInvalidCharacter, 0},
+		{"Ivan\x1b[31m", ErrInvalidCharacter, 0},
+		{"\xff\xfe", ErrInvalidCharacter, 0},
+		{"Admin", ErrReserved, 0},
+		{"аdmin", ErrReserved, 0},
+		{"ADM1N", ErrReserved, 0},
+		{"ａｄｍｉｎ", ErrReserved, 0},
+		{"a.d.m.i.n", ErrReserved, 0},
+		{"support team", ErrReserved, 0},
+		{"Ad\u200bmin", ErrReserved, 0},
+	}
+	for _, tt := range tests {
+		_, err := p.Normalize(tt.name)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Normalize(%q) = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		var violation *Violation
+		if errors.As(err, &violation) && violation.Limit != tt.limit {
+			t.Errorf("Normalize(%q): Limit = %d, want %d", tt.name, violation.Limit, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package emailaddr
+
+import (
+	_ "embed"
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrInvalid    = errors.New("invalid email address")
+	ErrDisposable = errors.New("disposable email domain")
+)
+
+const (
+	// maxLength and maxLocalLength are the RFC 5321 limits on a forward path
+	// and a local part.
+	maxLength      = 254
+	maxLocalLength = 64
+	maxLabelLength = 63
+)
+
+// disposable.txt lists domains of throwaway mailbox services, one per line.
+//
+//go:embed disposable.txt
+var disposableList string
+
+// Normalizer checks address syntax and brings addresses to the form they are
+// stored and looked up in.
+type Normalizer struct {
+	blocked map[string]bool
+}
+
+// New returns a normalizer. blockDisposable enables the bundled disposable
+// domain list and blocked adds more domains to it.
+func New(blockDisposable bool, blocked []string) *Normalizer {
+	domains := make(map[string]bool)
+	if blockDisposable {
+		for _, domain := range strings.Fields(disposableList) {
+			domains[domain] = true
+		}
+	}
+	for _, domain := range blocked {
+		if ascii, err := normalizeDomain(domain); err == nil {
+			domains[ascii] = true
+		}
+	}
+	return &Normalizer{blocked: domains}
+}
+
+// Normalize validates a bare RFC 5322 address, without a display name or
+// comments, and returns it lowercased with the domain converted to ASCII
+// (internationalized domains become punycode).
+func (n *Normalizer) Normalize(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", ErrInvalid
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" || parsed.Address != address {
+		return "", ErrInvalid
+	}
+
+	at := strings.LastIndexByte(address, '@')
+	local, domain := address[:at], address[at+1:]
+	if len(local) > maxLocalLength {
+		return "", ErrInvalid
+	}
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return "", err
+	}
+	// The email column compares case-insensitively, so the local part is
+	// lowercased too and the stored form agrees with it.
+	local = strings.ToLower(local)
+
+	normalized := local + "@" + domain
+	if len(normalized) > maxLength {
+		return "", ErrInvalid
+	}
+	return normalized, nil
+}
+
+// Disposable reports whether a normalized address belongs to a blocked
+// domain or one of its subdomains.
+func (n *Normalizer) Disposable(address string) bool {
+	domain := address[strings.LastIndexByte(address, '@')+1:]
+	for domain != "" {
+		if n.blocked[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+	return false
+}
+
+func normalizeDomain(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	ascii = strings.ToLower(ascii)
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalid
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", ErrInvalid
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", ErrInvalid
+			}
+		}
+	}
+	if tld := labels[len(labels)-1]; strings.Trim(tld, "0123456789") == "" {
+		return "", ErrInvalid
+	}
+	return ascii, nil
+}
@@ -0,0 +1,87 @@
+package emailaddr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"user@example.com", "user@example.com"},
+		{"  Bob@Example.COM ", "bob@example.com"},
+		{"first.last+tag@sub.example.org", "first.last+tag@sub.example.org"},
+		{"user@пример.рф", "user@xn--e1afmkfd.xn--p1ai"},
+		{"user@BÜCHER.de", "user@xn--bcher-kva.de"},
+		{"o'brien@example.ie", "o'brien@example.ie"},
+	}
+	n := New(false, nil)
+	for _, tt := range tests {
+		got, err := n.Normalize(tt.address)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.address, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"user",
+		"user@",
+		"@example.com",
+		"user@localhost",
+		"user@@example.com",
+		"user@example..com",
+		"user@-example.com",
+		"user@example.123",
+		"user@exa_mple.com",
+		"user name@example.com",
+		"Bob <bob@example.com>",
+		"<bob@example.com>",
+		"bob@example.com (Bob)",
+		"user.@example.com",
+		".user@example.com",
+		"user@[127.0.0.1]",
+		strings.Repeat("a", 65) + "@example.com",
+		"user@" + strings.Repeat("a", 64) + ".com",
+		"user@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com",
+	}
+	n := New(false, nil)
+	for _, address := range invalid {
+		if got, err := n.Normalize(address); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Normalize(%q) = %q, %v, want ErrInvalid", address, got, err)
+		}
+	}
+}
+
+func TestDisposable(t *testing.T) {
+	n := New(true, []string{"Blocked.Example", "почта.рф"})
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"user@mailinator.com", true},
+		{"user@eu.mailinator.com", true},
+		{"user@blocked.example", true},
+		{"user@xn--80a1acny.xn--p1ai", true},
+		{"user@example.com", false},
+		{"user@notmailinator.com", false},
+	}
+	for _, tt := range tests {
+		if got := n.Disposable(tt.address); got != tt.want {
+			t.Errorf("Disposable(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+
+	if New(false, nil).Disposable("user@mailinator.com") {
+		t.Error("Disposable with the list disabled = true")
+	}
+}
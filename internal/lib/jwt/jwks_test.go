@@ -0,0 +1,64 @@
+package jwt
+
+import (
+	"crypto/elliptic"
+	"encoding/base64"
+	"testing"
+)
+
+func TestPublicJWK(t *testing.T) {
+	tests := []struct {
+		key     *Key
+		kty     string
+		crv     string
+		sizes   map[string]int
+		present bool
+	}{
+		{mustHMACKey(t, "hs"), "", "", nil, false},
+		{mustKey(t, "rs", AlgRS256, pemPKCS8(t, newRSAKey(t))), "RSA", "", map[string]int{"n": 256, "e": 3}, true},
+		{mustKey(t, "es", AlgES256, pemPKCS8(t, newECKey(t, elliptic.P256()))), "EC", "P-256", map[string]int{"x": 32, "y": 32}, true},
+		{mustKey(t, "ed", AlgEdDSA, pemPKCS8(t, newEd25519Key(t))), "OKP", "Ed25519", map[string]int{"x": 32}, true},
+	}
+	for _, tt := range tests {
+		jwk, ok := tt.key.PublicJWK()
+		if ok != tt.present {
+			t.Errorf("%s: published = %v, want %v", tt.key.ID(), ok, tt.present)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if jwk.Kty != tt.kty || jwk.Crv != tt.crv || jwk.Kid != tt.key.ID() || jwk.Alg != tt.key.Algorithm() || jwk.Use != "sig" {
+			t.Errorf("%s: jwk = %+v", tt.key.ID(), jwk)
+		}
+
+		fields := map[string]string{"n": jwk.N, "e": jwk.E, "x": jwk.X, "y": jwk.Y}
+		for name, size := range tt.sizes {
+			decoded, err := base64.RawURLEncoding.DecodeString(fields[name])
+			if err != nil || len(decoded) != size {
+				t.Errorf("%s: %s is %d bytes (%v), want %d", tt.key.ID(), name, len(decoded), err, size)
+			}
+		}
+	}
+}
+
+func TestNewJSONWebKeySet(t *testing.T) {
+	hmac := mustHMACKey(t, "hs")
+	ring, err := NewKeyRing("hs", hmac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set := NewJSONWebKeySet(ring); set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("HMAC only set = %+v, want an empty list", set)
+	}
+
+	ed := mustKey(t, "ed", AlgEdDSA, pemPKCS8(t, newEd25519Key(t)))
+	ring, err = NewKeyRing("ed", ed, hmac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := NewJSONWebKeySet(ring)
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "ed" {
+		t.Errorf("set = %+v, want the ed key only", set)
+	}
+}
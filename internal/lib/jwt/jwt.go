@@ -3,36 +3,63 @@ package jwt
 import (
 	"auth-api/internal/domain/models"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrInvalidAudience = errors.New("token audience is not allowed")
+)
+
+// Every token kind has its own typ header, so one kind is never accepted
+// where another is expected even when both are signed by the same ring.
+const (
+	typAccess  = "at+jwt"
+	typRefresh = "rt+jwt"
+	typMFA     = "mfa+jwt"
+)
 
 type AccessClaims struct {
-	UserID string
-	Email  string
-	Name   string
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	SessionID string `json:"sid,omitempty"`
+	Version   int    `json:"ver"`
+	jwt.RegisteredClaims
 }
 
 type RefreshClaims struct {
-	UserID  string
-	TokenID string
+	jwt.RegisteredClaims
 }
 
-func NewAccessToken(user models.UserModel, secret string, duration time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"name":    user.Name,
-		"iat":     time.Now().Unix(),
-		"exp":     time.Now().Add(duration).Unix(),
-	}
+// MFAClaims identify a user who passed the password step of login and still
+// has to present a second factor.
+type MFAClaims struct {
+	jwt.RegisteredClaims
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func NewAccessToken(user models.UserModel, sessionID string, keys *KeyRing, issuer string, audience string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := AccessClaims{
+		Email:     user.Email,
+		Name:      user.Name,
+		SessionID: sessionID,
+		Version:   user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID,
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+	}
 
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := keys.sign(claims, typAccess)
 	if err != nil {
 		return "", err
 	}
@@ -40,17 +67,21 @@ func NewAccessToken(user models.UserModel, secret string, duration time.Duration
 	return tokenString, nil
 }
 
-func NewRefreshToken(userID string, tokenID string, secret string, duration time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"token_id": tokenID,
-		"iat":      time.Now().Unix(),
-		"exp":      time.Now().Add(duration).Unix(),
+func NewRefreshToken(userID string, tokenID string, keys *KeyRing, issuer string, audience string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := RefreshClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   userID,
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := keys.sign(claims, typRefresh)
 	if err != nil {
 		return "", err
 	}
@@ -58,67 +89,78 @@ func NewRefreshToken(userID string, tokenID string, secret string, duration time
 	return tokenString, nil
 }
 
-func ParseAccessToken(tokenStr string, secret string) (*AccessClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(secret), nil
-	})
+func NewMFAToken(userID string, keys *KeyRing, issuer string, audience string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := MFAClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userID,
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+	}
 
-	if err != nil || !token.Valid {
-		return nil, ErrInvalidToken
+	return keys.sign(claims, typMFA)
+}
+
+// ParseAccessToken verifies the token was issued by issuer for one of the
+// given audiences.
+func ParseAccessToken(tokenStr string, keys *KeyRing, issuer string, audiences []string) (*AccessClaims, error) {
+	var claims AccessClaims
+	if err := keys.parse(tokenStr, &claims, issuer, typAccess); err != nil {
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
+	if claims.Subject == "" || claims.ID == "" {
 		return nil, ErrInvalidToken
 	}
+	if !hasAudience(claims.Audience, audiences) {
+		return nil, ErrInvalidAudience
+	}
+
+	return &claims, nil
+}
 
-	userID, ok := claims["user_id"].(string)
-	email, okEmail := claims["email"].(string)
-	name, okName := claims["name"].(string)
+func ParseRefreshToken(tokenStr string, keys *KeyRing, issuer string, audiences []string) (*RefreshClaims, error) {
+	var claims RefreshClaims
+	if err := keys.parse(tokenStr, &claims, issuer, typRefresh); err != nil {
+		return nil, err
+	}
 
-	if !ok || !okEmail || !okName {
+	if claims.Subject == "" || claims.ID == "" {
 		return nil, ErrInvalidToken
 	}
+	if !hasAudience(claims.Audience, audiences) {
+		return nil, ErrInvalidAudience
+	}
 
-	return &AccessClaims{
-		UserID: userID,
-		Email:  email,
-		Name:   name,
-	}, nil
+	return &claims, nil
 }
 
-func ParseRefreshToken(tokenStr string, secret string) (*RefreshClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+func hasAudience(tokenAudience jwt.ClaimStrings, allowed []string) bool {
+	for _, aud := range tokenAudience {
+		if slices.Contains(allowed, aud) {
+			return true
 		}
-		return []byte(secret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return nil, ErrInvalidToken
 	}
+	return false
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, ErrInvalidToken
+func ParseMFAToken(tokenStr string, keys *KeyRing, issuer string, audiences []string) (*MFAClaims, error) {
+	var claims MFAClaims
+	if err := keys.parse(tokenStr, &claims, issuer, typMFA); err != nil {
+		return nil, err
 	}
 
-	userID, ok := claims["user_id"].(string)
-	if !ok {
+	if claims.Subject == "" || claims.ID == "" {
 		return nil, ErrInvalidToken
 	}
-
-	tokenID, ok := claims["token_id"].(string)
-	if !ok {
-		return nil, ErrInvalidToken
+	if !hasAudience(claims.Audience, audiences) {
+		return nil, ErrInvalidAudience
 	}
 
-	return &RefreshClaims{
-		UserID:  userID,
-		TokenID: tokenID,
-	}, nil
+	return &claims, nil
 }
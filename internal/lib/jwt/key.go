@@ -0,0 +1,129 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidKey           = errors.New("invalid signing key")
+)
+
+// Key signs and verifies tokens with a single algorithm. HMAC keys use the
+// same secret for both, asymmetric keys verify with the public half only.
+type Key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	retiresAt time.Time
+}
+
+func NewHMACKey(id string, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("%w: empty secret", ErrInvalidKey)
+	}
+	return &Key{
+		id:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}, nil
+}
+
+// LoadKey builds a key for the algorithm: HS256 uses the secret, the others
+// read a PEM encoded private key from path.
+func LoadKey(id string, algorithm string, secret string, path string) (*Key, error) {
+	if algorithm == "" || algorithm == AlgHS256 {
+		return NewHMACKey(id, secret)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key %q: %w", path, err)
+	}
+	return ParsePrivateKey(id, algorithm, data)
+}
+
+func ParsePrivateKey(id string, algorithm string, pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrInvalidKey)
+	}
+
+	private, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch algorithm {
+	case AlgRS256:
+		key, ok := private.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires an RSA key", ErrInvalidKey, algorithm)
+		}
+		return &Key{id: id, method: jwt.SigningMethodRS256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case AlgES256:
+		key, ok := private.(*ecdsa.PrivateKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: %s requires a P-256 key", ErrInvalidKey, algorithm)
+		}
+		return &Key{id: id, method: jwt.SigningMethodES256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case AlgEdDSA:
+		key, ok := private.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires an Ed25519 key", ErrInvalidKey, algorithm)
+		}
+		return &Key{id: id, method: jwt.SigningMethodEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+}
+
+func (k *Key) ID() string {
+	return k.id
+}
+
+func (k *Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// RetireAt stops the key from verifying tokens once t has passed.
+func (k *Key) RetireAt(t time.Time) {
+	k.retiresAt = t
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.retiresAt.IsZero() && !now.Before(k.retiresAt)
+}
+
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown private key format")
+}
@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"auth-api/internal/domain/models"
+)
+
+const (
+	testIssuer   = "auth-api"
+	testAudience = "app"
+)
+
+func pemPKCS8(t *testing.T, private any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustKey(t *testing.T, id string, algorithm string, pemData []byte) *Key {
+	t.Helper()
+	key, err := ParsePrivateKey(id, algorithm, pemData)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey(%s): %v", algorithm, err)
+	}
+	return key
+}
+
+func mustHMACKey(t *testing.T, id string) *Key {
+	t.Helper()
+	key, err := NewHMACKey(id, "secret-"+id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ecKey := newECKey(t, elliptic.P256())
+	edKey := newEd25519Key(t)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		pem       []byte
+		want      error
+	}{
+		{"rsa pkcs8", AlgRS256, pemPKCS8(t, rsaKey), nil},
+		{"rsa pkcs1", AlgRS256, pkcs1, nil},
+		{"ec pkcs8", AlgES256, pemPKCS8(t, ecKey), nil},
+		{"ec sec1", AlgES256, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), nil},
+		{"ed25519", AlgEdDSA, pemPKCS8(t, edKey), nil},
+		{"rsa as ES256", AlgES256, pemPKCS8(t, rsaKey), ErrInvalidKey},
+		{"ec as RS256", AlgRS256, pemPKCS8(t, ecKey), ErrInvalidKey},
+		{"rsa as EdDSA", AlgEdDSA, pemPKCS8(t, rsaKey), ErrInvalidKey},
+		{"P-384 as ES256", AlgES256, pemPKCS8(t, newECKey(t, elliptic.P384())), ErrInvalidKey},
+		{"unsupported algorithm", "PS256", pemPKCS8(t, rsaKey), ErrUnsupportedAlgorithm},
+		{"no pem block", AlgRS256, []byte("not a key"), ErrInvalidKey},
+		{"garbage der", AlgRS256, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}), ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		key, err := ParsePrivateKey("k1", tt.algorithm, tt.pem)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && (key.ID() != "k1" || key.Algorithm() != tt.algorithm) {
+			t.Errorf("%s: key = %s/%s", tt.name, key.ID(), key.Algorithm())
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pemPKCS8(t, newEd25519Key(t)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    string
+		path      string
+		want      string
+		wantErr   bool
+	}{
+		{"default is hmac", "", "secret", "", AlgHS256, false},
+		{"hmac", AlgHS256, "secret", path, AlgHS256, false},
+		{"empty secret", AlgHS256, "", "", "", true},
+		{"pem file", AlgEdDSA, "", path, AlgEdDSA, false},
+		{"missing file", AlgEdDSA, "", path + ".missing", "", true},
+	}
+	for _, tt := range tests {
+		key, err := LoadKey("k1", tt.algorithm, tt.secret, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && key.Algorithm() != tt.want {
+			t.Errorf("%s: Algorithm = %s, want %s", tt.name, key.Algorithm(), tt.want)
+		}
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	keys := []*Key{
+		mustHMACKey(t, "hs"),
+		mustKey(t, "rs", AlgRS256, pemPKCS8(t, newRSAKey(t))),
+		mustKey(t, "es", AlgES256, pemPKCS8(t, newECKey(t, elliptic.P256()))),
+		mustKey(t, "ed", AlgEdDSA, pemPKCS8(t, newEd25519Key(t))),
+	}
+	user := models.UserModel{ID: "user-1", Email: "user@example.com", Name: "User", TokenVersion: 3}
+
+	for _, key := range keys {
+		t.Run(key.Algorithm(), func(t *testing.T) {
+			ring, err := NewKeyRing(key.ID(), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := NewAccessToken(user, "session-1", ring, testIssuer, testAudience, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ParseAccessToken(token, ring, testIssuer, []string{testAudience})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != user.ID || claims.Email != user.Email || claims.Name != user.Name || claims.SessionID != "session-1" ||
+				claims.Version != user.TokenVersion {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// KeyRing signs with one active key and verifies with every key that has not
+// reached its retirement date, so secrets can be rotated without a mass logout.
+type KeyRing struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeyRing(activeID string, keys ...*Key) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]*Key, len(keys))}
+
+	for _, key := range keys {
+		if _, ok := ring.keys[key.id]; ok {
+			return nil, fmt.Errorf("%w: duplicate key id %q", ErrInvalidKey, key.id)
+		}
+		ring.keys[key.id] = key
+	}
+
+	active, ok := ring.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrKeyNotFound, activeID)
+	}
+	if active.retired(time.Now()) {
+		return nil, fmt.Errorf("%w: active key %q is retired", ErrInvalidKey, activeID)
+	}
+	ring.active = active
+
+	return ring, nil
+}
+
+// Keys returns the keys still accepted for verification.
+func (r *KeyRing) Keys() []*Key {
+	now := time.Now()
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		if !key.retired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// sign issues a token of the given typ header, so tokens meant for one
+// purpose cannot be replayed as another.
+func (r *KeyRing) sign(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.id
+	token.Header["typ"] = typ
+	return token.SignedString(r.active.signKey)
+}
+
+// parse verifies the token with the key named by its kid header. Tokens
+// issued before kid headers existed are checked against the active key.
+func (r *KeyRing) parse(tokenStr string, claims jwt.Claims, issuer string, typ string) error {
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Header["typ"] != typ {
+			return nil, ErrInvalidToken
+		}
+		key := r.active
+		if kid, ok := t.Header["kid"].(string); ok {
+			if key, ok = r.keys[kid]; !ok {
+				return nil, ErrKeyNotFound
+			}
+		}
+		if key.retired(time.Now()) || t.Method.Alg() != key.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.verifyKey, nil
+	}, jwt.WithIssuer(issuer), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+
+	if err != nil || !token.Valid {
+		return ErrInvalidToken
+	}
+	return nil
+}
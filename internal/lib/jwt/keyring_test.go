@@ -0,0 +1,239 @@
+package jwt
+
+import (
+	"auth-api/internal/domain/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	retired := mustHMACKey(t, "retired")
+	retired.RetireAt(time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name     string
+		activeID string
+		keys     []*Key
+		want     error
+	}{
+		{"single key", "k1", []*Key{mustHMACKey(t, "k1")}, nil},
+		{"rotation", "k2", []*Key{mustHMACKey(t, "k1"), mustHMACKey(t, "k2")}, nil},
+		{"unknown active", "k3", []*Key{mustHMACKey(t, "k1")}, ErrKeyNotFound},
+		{"duplicate id", "k1", []*Key{mustHMACKey(t, "k1"), mustHMACKey(t, "k1")}, ErrInvalidKey},
+		{"retired active", "retired", []*Key{retired}, ErrInvalidKey},
+		{"retired inactive", "k1", []*Key{mustHMACKey(t, "k1"), retired}, nil},
+	}
+	for _, tt := range tests {
+		if _, err := NewKeyRing(tt.activeID, tt.keys...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	old, current := mustHMACKey(t, "old"), mustHMACKey(t, "current")
+	before, err := NewKeyRing("old", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := NewRefreshToken("user-1", "token-1", before, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewKeyRing("current", old, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseRefreshToken(token, after, testIssuer, []string{testAudience}); err != nil {
+		t.Errorf("token of the previous key: %v", err)
+	}
+	issued, err := NewRefreshToken("user-1", "token-2", after, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := header(t, issued)["kid"]; kid != "current" {
+		t.Errorf("kid = %v, want current", kid)
+	}
+
+	old.RetireAt(time.Now())
+	if _, err := ParseRefreshToken(token, after, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a retired key: err = %v, want ErrInvalidToken", err)
+	}
+	if keys := after.Keys(); len(keys) != 1 || keys[0] != current {
+		t.Errorf("Keys = %v, want the current key only", keys)
+	}
+
+	without, err := NewKeyRing("current", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseRefreshToken(token, without, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a removed key: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestKeyRingParse(t *testing.T) {
+	key := mustHMACKey(t, "k1")
+	ring, err := NewKeyRing("k1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, err := NewHMACKey("k1", "another secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewKeyRing("k1", impostor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			ID:        "token-1",
+			Subject:   "user-1",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		}
+	}
+	sign := func(kid any, claims jwt.Claims, method jwt.SigningMethod, secret any) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["typ"] = typRefresh
+		if kid != nil {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	secret := []byte("secret-k1")
+	expired := claims()
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Second))
+	noExpiry := claims()
+	noExpiry.ExpiresAt = nil
+	foreign := claims()
+	foreign.Issuer = "someone-else"
+
+	valid, err := NewRefreshToken("user-1", "token-1", ring, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := NewRefreshToken("user-1", "token-1", other, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"without kid", sign(nil, claims(), jwt.SigningMethodHS256, secret), nil},
+		{"unknown kid", sign("k9", claims(), jwt.SigningMethodHS256, secret), ErrInvalidToken},
+		{"other secret under the same kid", forged, ErrInvalidToken},
+		{"algorithm mismatch", sign("k1", claims(), jwt.SigningMethodHS512, secret), ErrInvalidToken},
+		{"none algorithm", sign("k1", claims(), jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), ErrInvalidToken},
+		{"expired", sign("k1", expired, jwt.SigningMethodHS256, secret), ErrInvalidToken},
+		{"no expiry", sign("k1", noExpiry, jwt.SigningMethodHS256, secret), ErrInvalidToken},
+		{"other issuer", sign("k1", foreign, jwt.SigningMethodHS256, secret), ErrInvalidToken},
+		{"garbage", "not.a.token", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if _, err := ParseRefreshToken(tt.token, ring, testIssuer, []string{testAudience}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := ParseRefreshToken(valid, ring, testIssuer, []string{"other-app"}); !errors.Is(err, ErrInvalidAudience) {
+		t.Errorf("other audience: err = %v, want ErrInvalidAudience", err)
+	}
+}
+
+func header(t *testing.T, token string) map[string]any {
+	t.Helper()
+	encoded, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h map[string]any
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestMFAToken(t *testing.T) {
+	ring, err := NewKeyRing("k1", mustHMACKey(t, "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := NewMFAToken("user-1", ring, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := NewRefreshToken("user-1", "token-1", ring, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseMFAToken(mfa, ring, testIssuer, []string{testAudience})
+	if err != nil {
+		t.Fatalf("ParseMFAToken: %v", err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("subject = %q, want user-1", claims.Subject)
+	}
+
+	// Tokens signed by the same ring are not interchangeable.
+	if _, err := ParseRefreshToken(mfa, ring, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRefreshToken of an mfa token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := ParseMFAToken(refresh, ring, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseMFAToken of a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := ParseMFAToken(mfa, ring, testIssuer, []string{"other-app"}); !errors.Is(err, ErrInvalidAudience) {
+		t.Errorf("other audience: err = %v, want ErrInvalidAudience", err)
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	ring, err := NewKeyRing("k1", mustHMACKey(t, "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := NewAccessToken(models.UserModel{ID: "user-1"}, "session-1", ring, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := NewRefreshToken("user-1", "token-1", ring, testIssuer, testAudience, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := header(t, access)["typ"]; typ != "at+jwt" {
+		t.Errorf("access token typ = %v, want at+jwt", typ)
+	}
+	if typ := header(t, refresh)["typ"]; typ != "rt+jwt" {
+		t.Errorf("refresh token typ = %v, want rt+jwt", typ)
+	}
+
+	if _, err := ParseAccessToken(refresh, ring, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseAccessToken of a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := ParseRefreshToken(access, ring, testIssuer, []string{testAudience}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRefreshToken of an access token: err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,158 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := Message{To: "user@example.com", Subject: "Подтверждение", Body: "https://example.com/verify?token=abc\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "_user@example.com.eml") {
+		t.Fatalf("files = %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers, body, ok := strings.Cut(string(data), "\r\n\r\n")
+	if !ok || body != msg.Body {
+		t.Errorf("body = %q, want %q", body, msg.Body)
+	}
+	for _, want := range []string{
+		"From: noreply@example.com",
+		"To: user@example.com",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !strings.Contains(headers, want) {
+			t.Errorf("headers have no %q:\n%s", want, headers)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	if got := sanitize(`../a\b:c@example.com`); got != `.._a_b_c@example.com` {
+		t.Errorf("sanitize = %q", got)
+	}
+}
+
+// fakeSMTP answers one SMTP conversation and returns the received data.
+func fakeSMTP(t *testing.T, ln net.Listener) <-chan string {
+	t.Helper()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case strings.HasPrefix(cmd, "DATA"):
+				fmt.Fprint(conn, "354 go ahead\r\n")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				fmt.Fprint(conn, "250 queued\r\n")
+			case strings.HasPrefix(cmd, "QUIT"):
+				fmt.Fprint(conn, "221 bye\r\n")
+				received <- data.String()
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := fakeSMTP(t, ln)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	m := NewSMTPMailer("127.0.0.1", addr.Port, "", "", "noreply@example.com", 5*time.Second)
+	msg := Message{To: "user@example.com", Subject: "Подтверждение", Body: "https://example.com/verify?token=abc\r\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-received; !strings.Contains(data, "To: user@example.com") || !strings.HasSuffix(data, msg.Body) {
+		t.Errorf("data = %q", data)
+	}
+
+	if err := m.Send(context.Background(), Message{To: "user@example.com\r\nBcc: x@example.com"}); err == nil {
+		t.Error("Send accepted a recipient with CRLF")
+	}
+}
+
+func TestSMTPMailerTimeout(t *testing.T) {
+	// The server accepts the connection and never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	m := NewSMTPMailer("127.0.0.1", addr.Port, "", "", "noreply@example.com", time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := m.Send(ctx, Message{To: "user@example.com"}); err == nil {
+		t.Fatal("Send to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v, want it bounded by ctx", elapsed)
+	}
+}
@@ -0,0 +1,26 @@
+package onetime
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const tokenSize = 32
+
+// New returns a random URL-safe token together with the hash that should be
+// stored instead of the token itself.
+func New() (token string, hash []byte, err error) {
+	raw := make([]byte, tokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, Hash(token), nil
+}
+
+func Hash(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,29 @@
+package onetime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	token, hash, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != tokenSize {
+		t.Errorf("token %q decodes to %d bytes (%v), want %d", token, len(raw), err, tokenSize)
+	}
+	if !bytes.Equal(hash, Hash(token)) {
+		t.Error("hash does not match the token")
+	}
+
+	other, otherHash, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == token || bytes.Equal(otherHash, hash) {
+		t.Error("two tokens are equal")
+	}
+}
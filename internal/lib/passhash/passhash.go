@@ -0,0 +1,242 @@
+package passhash
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownHash   = errors.New("unknown hash format")
+	ErrUnknownPepper = errors.New("unknown pepper version")
+)
+
+// minPepperLength is the shortest accepted pepper key in bytes.
+const minPepperLength = 32
+
+// Argon2Params are the Argon2id costs. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Pepper holds HMAC-SHA256 keys by version. Passwords are run through the
+// current key before hashing, so a database dump alone is not enough to test
+// guesses offline. Version 0 stands for hashes made without a pepper; old
+// versions have to be kept until no stored hash uses them.
+type Pepper struct {
+	current int
+	keys    map[int][]byte
+}
+
+func NewPepper(current int, keys map[int][]byte) (*Pepper, error) {
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("pepper version %d: versions start at 1", version)
+		}
+		if len(key) < minPepperLength {
+			return nil, fmt.Errorf("pepper version %d: key must be at least %d bytes", version, minPepperLength)
+		}
+	}
+	if _, ok := keys[current]; current != 0 && !ok {
+		return nil, fmt.Errorf("current pepper version %d has no key", current)
+	}
+	return &Pepper{current: current, keys: keys}, nil
+}
+
+func (p *Pepper) version() int {
+	if p == nil {
+		return 0
+	}
+	return p.current
+}
+
+func (p *Pepper) apply(version int, password string) (string, error) {
+	if version == 0 {
+		return password, nil
+	}
+	if p == nil {
+		return "", ErrUnknownPepper
+	}
+	key, ok := p.keys[version]
+	if !ok {
+		return "", ErrUnknownPepper
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Hasher hashes new passwords with one algorithm and verifies hashes of
+// every supported one. Hashes are stored in PHC string format, e.g.
+// "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>"; bcrypt keeps its own
+// "$2a$<cost>$..." form, which existing hashes already use. The pepper
+// version a hash was made with is kept next to it by the caller.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+	pepper     *Pepper
+}
+
+// New returns a hasher; a nil pepper hashes passwords as they are.
+func New(algorithm string, argon2 Argon2Params, bcryptCost int, pepper *Pepper) (*Hasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		if argon2.Memory == 0 || argon2.Iterations == 0 || argon2.Parallelism == 0 {
+			return nil, errors.New("argon2id memory, iterations and parallelism must be positive")
+		}
+		if argon2.SaltLength < 8 || argon2.KeyLength < 16 {
+			return nil, errors.New("argon2id salt must be at least 8 bytes and key at least 16")
+		}
+	case AlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+	return &Hasher{algorithm: algorithm, argon2: argon2, bcryptCost: bcryptCost, pepper: pepper}, nil
+}
+
+func (h *Hasher) Algorithm() string {
+	return h.algorithm
+}
+
+// Hash returns the hash together with the pepper version it was made with.
+func (h *Hasher) Hash(password string) ([]byte, int, error) {
+	version := h.pepper.version()
+	password, err := h.pepper.apply(version, password)
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err := h.hash(password)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, version, nil
+}
+
+func (h *Hasher) hash(password string) ([]byte, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+	return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id, argon2.Version,
+		h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// Verify returns ErrMismatch when the password does not match the hash,
+// ErrUnknownHash when the hash cannot be parsed and ErrUnknownPepper when the
+// pepper version is no longer configured.
+func (h *Hasher) Verify(hash []byte, pepper int, password string) error {
+	password, err := h.pepper.apply(pepper, password)
+	if err != nil {
+		return err
+	}
+
+	switch algorithm(hash) {
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	case AlgorithmArgon2id:
+		params, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+	return ErrUnknownHash
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm,
+// other costs or another pepper version than new hashes are.
+func (h *Hasher) NeedsRehash(hash []byte, pepper int) bool {
+	if pepper != h.pepper.version() || algorithm(hash) != h.algorithm {
+		return true
+	}
+	if h.algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost(hash)
+		return err != nil || cost != h.bcryptCost
+	}
+	params, salt, _, err := parseArgon2(hash)
+	return err != nil ||
+		params.Memory != h.argon2.Memory ||
+		params.Iterations != h.argon2.Iterations ||
+		params.Parallelism != h.argon2.Parallelism ||
+		params.KeyLength != h.argon2.KeyLength ||
+		uint32(len(salt)) != h.argon2.SaltLength
+}
+
+func algorithm(hash []byte) string {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$argon2id$")):
+		return AlgorithmArgon2id
+	case bytes.HasPrefix(hash, []byte("$2a$")),
+		bytes.HasPrefix(hash, []byte("$2b$")),
+		bytes.HasPrefix(hash, []byte("$2y$")):
+		return AlgorithmBcrypt
+	}
+	return ""
+}
+
+func parseArgon2(hash []byte) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,228 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func mustNew(t *testing.T, algorithm string, params Argon2Params, cost int) *Hasher {
+	t.Helper()
+	h, err := New(algorithm, params, cost, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHashVerify(t *testing.T) {
+	for _, h := range []*Hasher{
+		mustNew(t, AlgorithmArgon2id, testArgon2, 0),
+		mustNew(t, AlgorithmBcrypt, Argon2Params{}, bcrypt.MinCost),
+	} {
+		hash, _, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: Hash: %v", h.Algorithm(), err)
+		}
+		if got := algorithm(hash); got != h.Algorithm() {
+			t.Errorf("%s: hash %q detected as %q", h.Algorithm(), hash, got)
+		}
+		if err := h.Verify(hash, 0, "correct horse"); err != nil {
+			t.Errorf("%s: Verify with the right password: %v", h.Algorithm(), err)
+		}
+		if err := h.Verify(hash, 0, "wrong horse"); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: Verify with a wrong password: err = %v, want ErrMismatch", h.Algorithm(), err)
+		}
+		if h.NeedsRehash(hash, 0) {
+			t.Errorf("%s: NeedsRehash on a fresh hash = true", h.Algorithm())
+		}
+	}
+}
+
+func TestArgon2Format(t *testing.T) {
+	h := mustNew(t, AlgorithmArgon2id, testArgon2, 0)
+	hash, _, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash = %q, want a PHC argon2id string", hash)
+	}
+	other, _, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other) == string(hash) {
+		t.Error("two hashes of one password are equal, salt is not random")
+	}
+}
+
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	argon := mustNew(t, AlgorithmArgon2id, testArgon2, 0)
+	bcryptHasher := mustNew(t, AlgorithmBcrypt, Argon2Params{}, bcrypt.MinCost)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := argon.Verify(legacy, 0, "secret"); err != nil {
+		t.Errorf("argon2id hasher verifying a bcrypt hash: %v", err)
+	}
+	hash, _, err := argon.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcryptHasher.Verify(hash, 0, "secret"); err != nil {
+		t.Errorf("bcrypt hasher verifying an argon2id hash: %v", err)
+	}
+
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=64,t=1,p=1$salt", "$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5", "$argon2id$v=19$m=x$c2FsdHNhbHQ$a2V5"} {
+		if err := argon.Verify([]byte(hash), 0, "secret"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Verify(%q): err = %v, want ErrUnknownHash", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := mustNew(t, AlgorithmArgon2id, testArgon2, 0)
+	stronger := testArgon2
+	stronger.Iterations = 2
+	argonStronger := mustNew(t, AlgorithmArgon2id, stronger, 0)
+	bcrypt4 := mustNew(t, AlgorithmBcrypt, Argon2Params{}, bcrypt.MinCost)
+	bcrypt5 := mustNew(t, AlgorithmBcrypt, Argon2Params{}, bcrypt.MinCost+1)
+
+	argonHash, _, err := argon.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, _, err := bcrypt4.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   []byte
+		want   bool
+	}{
+		{"same argon2id params", argon, argonHash, false},
+		{"more argon2id iterations", argonStronger, argonHash, true},
+		{"bcrypt to argon2id", argon, bcryptHash, true},
+		{"same bcrypt cost", bcrypt4, bcryptHash, false},
+		{"higher bcrypt cost", bcrypt5, bcryptHash, true},
+		{"argon2id to bcrypt", bcrypt4, argonHash, true},
+		{"unknown hash", argon, []byte("plain"), true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash, 0); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		params    Argon2Params
+		cost      int
+		ok        bool
+	}{
+		{"argon2id", AlgorithmArgon2id, testArgon2, 0, true},
+		{"argon2id without memory", AlgorithmArgon2id, Argon2Params{Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, 0, false},
+		{"argon2id short salt", AlgorithmArgon2id, Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}, 0, false},
+		{"bcrypt", AlgorithmBcrypt, Argon2Params{}, 10, true},
+		{"bcrypt cost too low", AlgorithmBcrypt, Argon2Params{}, 2, false},
+		{"unknown", "md5", Argon2Params{}, 0, false},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.algorithm, tt.params, tt.cost, nil); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestPepper(t *testing.T) {
+	keys := map[int][]byte{1: []byte(strings.Repeat("a", 32)), 2: []byte(strings.Repeat("b", 32))}
+	v1, err := NewPepper(1, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := NewPepper(2, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := mustNew(t, AlgorithmArgon2id, testArgon2, 0)
+	peppered, err := New(AlgorithmArgon2id, testArgon2, 0, v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := New(AlgorithmArgon2id, testArgon2, 0, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, version, err := peppered.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("pepper version = %d, want 1", version)
+	}
+	if err := peppered.Verify(hash, version, "secret"); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := plain.Verify(hash, 0, "secret"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify without the pepper: err = %v, want ErrMismatch", err)
+	}
+	if err := plain.Verify(hash, version, "secret"); !errors.Is(err, ErrUnknownPepper) {
+		t.Errorf("Verify with an unknown version: err = %v, want ErrUnknownPepper", err)
+	}
+
+	// A rotated hasher still verifies older versions and asks for a rehash.
+	if err := rotated.Verify(hash, version, "secret"); err != nil {
+		t.Errorf("Verify after rotation: %v", err)
+	}
+	if peppered.NeedsRehash(hash, version) {
+		t.Error("NeedsRehash with the current pepper = true")
+	}
+	if !rotated.NeedsRehash(hash, version) {
+		t.Error("NeedsRehash with an old pepper = false")
+	}
+	unpeppered, _, err := plain.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peppered.Verify(unpeppered, 0, "secret"); err != nil {
+		t.Errorf("Verify of a hash made before the pepper: %v", err)
+	}
+	if !peppered.NeedsRehash(unpeppered, 0) {
+		t.Error("NeedsRehash of a hash made before the pepper = false")
+	}
+}
+
+func TestNewPepper(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	tests := []struct {
+		name    string
+		current int
+		keys    map[int][]byte
+		ok      bool
+	}{
+		{"disabled", 0, nil, true},
+		{"current key", 1, map[int][]byte{1: key}, true},
+		{"missing current key", 2, map[int][]byte{1: key}, false},
+		{"short key", 1, map[int][]byte{1: key[:16]}, false},
+		{"version zero", 0, map[int][]byte{0: key}, false},
+	}
+	for _, tt := range tests {
+		if _, err := NewPepper(tt.current, tt.keys); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package password
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// common.txt lists frequently used passwords, most common first.
+//
+//go:embed common.txt
+var commonList string
+
+var commonRank = func() map[string]int {
+	words := strings.Fields(commonList)
+	rank := make(map[string]int, len(words))
+	for i, word := range words {
+		rank[word] = i + 1
+	}
+	return rank
+}()
+
+// IsCommon reports whether the lowercased password is on the bundled list.
+func IsCommon(password string) bool {
+	_, ok := commonRank[password]
+	return ok
+}
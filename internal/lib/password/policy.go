@@ -0,0 +1,126 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort         = errors.New("password is too short")
+	ErrTooLong          = errors.New("password is too long")
+	ErrTooManyBytes     = errors.New("password is too long in bytes")
+	ErrMissingLower     = errors.New("password has no lowercase letter")
+	ErrMissingUpper     = errors.New("password has no uppercase letter")
+	ErrMissingDigit     = errors.New("password has no digit")
+	ErrMissingSymbol    = errors.New("password has no symbol")
+	ErrContainsPersonal = errors.New("password contains personal data")
+	ErrCommon           = errors.New("password is too common")
+	ErrTooWeak          = errors.New("password is too weak")
+	ErrBreached         = errors.New("password appears in a data breach")
+)
+
+// Violation is returned by Policy.Check. Limit carries the bound that was
+// violated (length or score) for building a message.
+type Violation struct {
+	Err   error
+	Limit int
+}
+
+func (v *Violation) Error() string {
+	return v.Err.Error()
+}
+
+func (v *Violation) Unwrap() error {
+	return v.Err
+}
+
+// Policy describes acceptable passwords. Lengths are counted in characters,
+// MaxBytes bounds the UTF-8 encoding for hashers that truncate their input.
+// MinScore is the minimal Strength score, 0 disables the check.
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	MaxBytes       int
+	RequireLower   bool
+	RequireUpper   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectPersonal bool
+	RejectCommon   bool
+	MinScore       int
+}
+
+// minPersonalLength is the shortest email local part or name word treated as
+// personal data; shorter ones match too many passwords by accident.
+const minPersonalLength = 3
+
+// Check validates the password. personal lists the user's email and name,
+// which must not appear in the password.
+func (p *Policy) Check(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return &Violation{Err: ErrTooShort, Limit: p.MinLength}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &Violation{Err: ErrTooLong, Limit: p.MaxLength}
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return &Violation{Err: ErrTooManyBytes, Limit: p.MaxBytes}
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireLower && !lower:
+		return &Violation{Err: ErrMissingLower}
+	case p.RequireUpper && !upper:
+		return &Violation{Err: ErrMissingUpper}
+	case p.RequireDigit && !digit:
+		return &Violation{Err: ErrMissingDigit}
+	case p.RequireSymbol && !symbol:
+		return &Violation{Err: ErrMissingSymbol}
+	}
+
+	folded := strings.ToLower(password)
+	if p.RejectPersonal && containsPersonal(folded, personal) {
+		return &Violation{Err: ErrContainsPersonal}
+	}
+	if p.RejectCommon && IsCommon(folded) {
+		return &Violation{Err: ErrCommon}
+	}
+	if p.MinScore > 0 && Strength(password, personal...) < p.MinScore {
+		return &Violation{Err: ErrTooWeak, Limit: p.MinScore}
+	}
+
+	return nil
+}
+
+func containsPersonal(password string, personal []string) bool {
+	for _, value := range personal {
+		value = strings.ToLower(value)
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if utf8.RuneCountInString(part) >= minPersonalLength && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	strict := Policy{
+		MinLength:      8,
+		MaxLength:      64,
+		RequireLower:   true,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		RejectPersonal: true,
+		RejectCommon:   true,
+	}
+	personal := []string{"ivan.petrov@example.com", "Иван Петров"}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		want     error
+		limit    int
+	}{
+		{"valid", strict, "Tr0ub4dor&3x", nil, 0},
+		{"too short", strict, "Aa1!", ErrTooShort, 8},
+		{"too long", strict, "Aa1!" + strings.Repeat("x", 61), ErrTooLong, 64},
+		{"length in characters", Policy{MinLength: 4, MaxLength: 4}, "паро", nil, 0},
+		{"too many bytes", Policy{MaxBytes: 72}, strings.Repeat("ж", 37), ErrTooManyBytes, 72},
+		{"bytes within limit", Policy{MaxBytes: 72}, strings.Repeat("ж", 36), nil, 0},
+		{"no lowercase", strict, "TR0UB4DOR&3X", ErrMissingLower, 0},
+		{"no uppercase", strict, "tr0ub4dor&3x", ErrMissingUpper, 0},
+		{"no digit", strict, "Troubador&x!", ErrMissingDigit, 0},
+		{"no symbol", strict, "Tr0ub4dor3xQ", ErrMissingSymbol, 0},
+		{"cyrillic letters", strict, "Пар0ль!секрет", nil, 0},
+		{"email local part", strict, "Petrov#2024x", ErrContainsPersonal, 0},
+		{"name word", strict, "1ИВАН!parol", ErrContainsPersonal, 0},
+		{"common", Policy{RejectCommon: true}, "PassWord", ErrCommon, 0},
+		{"common allowed", Policy{}, "password", nil, 0},
+		{"too weak", Policy{MinScore: 3}, "qwerty123", ErrTooWeak, 3},
+		{"strong enough", Policy{MinScore: 3}, "correct-horse-battery-staple", nil, 0},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Check(tt.password, personal...)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check(%q) = %v, want %v", tt.name, tt.password, err, tt.want)
+			continue
+		}
+		var violation *Violation
+		if errors.As(err, &violation) && violation.Limit != tt.limit {
+			t.Errorf("%s: Limit = %d, want %d", tt.name, violation.Limit, tt.limit)
+		}
+	}
+}
+
+func TestContainsPersonal(t *testing.T) {
+	tests := []struct {
+		password string
+		personal []string
+		want     bool
+	}{
+		{"xxpetrovxx", []string{"ivan.petrov@example.com"}, true},
+		{"xxexamplexx", []string{"ivan.petrov@example.com"}, false},
+		{"мойиван1", []string{"Иван Петров"}, true},
+		{"abc-password", []string{"Al Bo"}, false},
+		{"xxalbo", []string{"al-bo"}, false},
+		{"anything", nil, false},
+	}
+	for _, tt := range tests {
+		if got := containsPersonal(tt.password, tt.personal); got != tt.want {
+			t.Errorf("containsPersonal(%q, %q) = %v, want %v", tt.password, tt.personal, got, tt.want)
+		}
+	}
+}
+
+func TestIsCommon(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"123456", true},
+		{"password", true},
+		{"qwerty", true},
+		{"Password", false},
+		{"vq8#Lm2!zR", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommon(tt.password); got != tt.want {
+			t.Errorf("IsCommon(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxPatternLength bounds the segments tried as a single pattern, which
+	// keeps the estimate quadratic only in this constant.
+	maxPatternLength = 32
+	// minPatternGuesses is the floor for any multi-character pattern.
+	minPatternGuesses = 50
+)
+
+var keyboardRows = []string{
+	"1234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"йцукенгшщзхъ",
+	"фывапролджэ",
+	"ячсмитьбю",
+}
+
+var leet = strings.NewReplacer(
+	"4", "a", "@", "a", "3", "e", "1", "i", "!", "i",
+	"0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+// Strength estimates how hard the password is to guess on a 0..4 scale, in
+// the spirit of zxcvbn: the password is split into the cheapest sequence of
+// known patterns (common passwords, personal data, repeats, sequences and
+// keyboard runs) and brute forced characters, and the resulting number of
+// guesses is mapped onto the score.
+func Strength(password string, personal ...string) int {
+	original := []rune(password)
+	folded := []rune(strings.ToLower(password))
+	dictionary := personalWords(personal)
+
+	// best[i] is the log10 of guesses needed for the first i characters.
+	best := make([]float64, len(folded)+1)
+	for j := 1; j <= len(folded); j++ {
+		best[j] = best[j-1] + math.Log10(cardinality(original[j-1]))
+		for i := max(0, j-maxPatternLength); i < j-1; i++ {
+			if cost, ok := patternCost(original[i:j], folded[i:j], dictionary); ok {
+				best[j] = min(best[j], best[i]+cost)
+			}
+		}
+	}
+
+	switch guesses := best[len(folded)]; {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// patternCost returns log10 guesses of the cheapest pattern matching the
+// whole segment.
+func patternCost(original []rune, folded []rune, personal map[string]bool) (float64, bool) {
+	word := string(folded)
+	cost, found := math.Inf(1), false
+	consider := func(c float64) {
+		cost, found = min(cost, max(c, math.Log10(minPatternGuesses))), true
+	}
+
+	variations := 0.0
+	if string(original) != word {
+		variations = math.Log10(2)
+	}
+	if personal[word] {
+		consider(variations)
+	}
+	if rank, ok := commonRank[word]; ok {
+		consider(math.Log10(float64(rank)) + variations)
+	}
+	if unleeted := leet.Replace(word); unleeted != word {
+		if personal[unleeted] {
+			consider(variations + math.Log10(2))
+		}
+		if rank, ok := commonRank[unleeted]; ok {
+			consider(math.Log10(float64(rank)) + variations + math.Log10(2))
+		}
+	}
+
+	if len(folded) >= 3 {
+		if repeated(folded) {
+			consider(math.Log10(cardinality(original[0]) * float64(len(folded))))
+		}
+		if step := sequenceStep(folded); step != 0 {
+			c := math.Log10(cardinality(original[0]) * float64(len(folded)))
+			if step < 0 {
+				c += math.Log10(2)
+			}
+			consider(c)
+		}
+		if block, times := repeatedBlock(folded); times > 1 {
+			consider(bruteForce(original[:block]) + math.Log10(float64(times)))
+		}
+	}
+	if len(folded) >= 4 && onKeyboard(word) {
+		consider(math.Log10(float64(len(keyboardRows)) * 2 * float64(len(folded))))
+	}
+
+	return cost, found
+}
+
+func personalWords(personal []string) map[string]bool {
+	words := make(map[string]bool)
+	for _, value := range personal {
+		value = strings.ToLower(value)
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		words[value] = true
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words[part] = true
+		}
+	}
+	return words
+}
+
+func repeated(s []rune) bool {
+	for _, r := range s[1:] {
+		if r != s[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// sequenceStep returns +1 or -1 for runs like "abc" or "321", 0 otherwise.
+func sequenceStep(s []rune) int {
+	step := s[1] - s[0]
+	if step != 1 && step != -1 {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i]-s[i-1] != step {
+			return 0
+		}
+	}
+	return int(step)
+}
+
+// repeatedBlock finds the shortest block the segment is made of, like "ab"
+// in "ababab".
+func repeatedBlock(s []rune) (int, int) {
+	for block := 2; block <= len(s)/2; block++ {
+		if len(s)%block != 0 {
+			continue
+		}
+		match := true
+		for i := block; i < len(s) && match; i++ {
+			match = s[i] == s[i-block]
+		}
+		if match {
+			return block, len(s) / block
+		}
+	}
+	return 0, 0
+}
+
+func onKeyboard(s string) bool {
+	reversed := []rune(s)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func bruteForce(s []rune) float64 {
+	var guesses float64
+	for _, r := range s {
+		guesses += math.Log10(cardinality(r))
+	}
+	return guesses
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	}
+	return 33
+}
@@ -0,0 +1,82 @@
+package password
+
+import "testing"
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		personal []string
+		min, max int
+	}{
+		{"", nil, 0, 0},
+		{"password", nil, 0, 0},
+		{"P@ssw0rd", nil, 0, 1},
+		{"aaaaaaaaaaaa", nil, 0, 1},
+		{"abcdefghij", nil, 0, 1},
+		{"9876543210", nil, 0, 1},
+		{"qwertyuiop", nil, 0, 1},
+		{"йцукенгшщз", nil, 0, 1},
+		{"abcabcabcabc", nil, 0, 2},
+		{"ivanpetrov", []string{"ivan.petrov@example.com"}, 0, 1},
+		{"ivanpetrov", nil, 3, 4},
+		{"correct-horse-battery-staple", nil, 4, 4},
+		{"vq8#Lm2!zR7&", nil, 4, 4},
+	}
+	for _, tt := range tests {
+		if got := Strength(tt.password, tt.personal...); got < tt.min || got > tt.max {
+			t.Errorf("Strength(%q) = %d, want %d..%d", tt.password, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	if !repeated([]rune("zzzz")) || repeated([]rune("zzza")) {
+		t.Error("repeated")
+	}
+
+	sequences := []struct {
+		s    string
+		want int
+	}{
+		{"abc", 1},
+		{"321", -1},
+		{"абв", 1},
+		{"ace", 0},
+		{"abd", 0},
+	}
+	for _, tt := range sequences {
+		if got := sequenceStep([]rune(tt.s)); got != tt.want {
+			t.Errorf("sequenceStep(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+
+	blocks := []struct {
+		s            string
+		block, times int
+	}{
+		{"ababab", 2, 3},
+		{"abcabc", 3, 2},
+		{"abcab", 0, 0},
+		{"aaaa", 2, 2},
+	}
+	for _, tt := range blocks {
+		if block, times := repeatedBlock([]rune(tt.s)); block != tt.block || times != tt.times {
+			t.Errorf("repeatedBlock(%q) = %d, %d, want %d, %d", tt.s, block, times, tt.block, tt.times)
+		}
+	}
+
+	keyboard := []struct {
+		s    string
+		want bool
+	}{
+		{"qwer", true},
+		{"poiu", true},
+		{"фыва", true},
+		{"qwas", false},
+	}
+	for _, tt := range keyboard {
+		if got := onKeyboard(tt.s); got != tt.want {
+			t.Errorf("onKeyboard(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
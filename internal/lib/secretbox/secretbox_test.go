@@ -0,0 +1,86 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newBox(t *testing.T, seed byte) *Box {
+	t.Helper()
+	box, err := New(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"32 bytes", base64.StdEncoding.EncodeToString(make([]byte, 32)), false},
+		{"16 bytes", base64.StdEncoding.EncodeToString(make([]byte, 16)), true},
+		{"not base64", "not base64!", true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.key); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	box := newBox(t, 1)
+	plaintext := []byte("JBSWY3DPEHPK3PXP")
+
+	first, err := box.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := box.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("Seal reused a nonce")
+	}
+
+	got, err := box.Open(first)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Open = %q, want %q", got, plaintext)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	box := newBox(t, 1)
+	sealed, err := box.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		box        *Box
+		ciphertext []byte
+	}{
+		{"tampered", box, tampered},
+		{"other key", newBox(t, 2), sealed},
+		{"short", box, sealed[:4]},
+		{"empty", box, nil},
+	}
+	for _, tt := range tests {
+		if _, err := tt.box.Open(tt.ciphertext); !errors.Is(err, ErrInvalidCiphertext) {
+			t.Errorf("%s: err = %v, want ErrInvalidCiphertext", tt.name, err)
+		}
+	}
+}
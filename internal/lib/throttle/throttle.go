@@ -0,0 +1,100 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps failure counters per key. Counters older than the window start
+// over from one. PurgeLoginFailures removes counters under prefix that fell out
+// of the window and are not blocked.
+type Store interface {
+	LoginBlockedUntil(ctx context.Context, key string) (time.Time, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	BlockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	PurgeLoginFailures(ctx context.Context, prefix string, window time.Duration) (int64, error)
+}
+
+// Policy allows FreeAttempts failures within Window, then blocks the key for
+// BaseDelay, doubling with every further failure up to MaxDelay.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Window       time.Duration
+}
+
+func (p Policy) Delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for range failures - p.FreeAttempts {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Throttler applies a policy to keys of one kind, e.g. accounts or client
+// addresses. The name prefixes keys so several throttlers can share a store.
+type Throttler struct {
+	name   string
+	store  Store
+	policy Policy
+}
+
+func New(name string, store Store, policy Policy) *Throttler {
+	return &Throttler{name: name, store: store, policy: policy}
+}
+
+// Wait returns how long the key is still blocked, zero if it is not.
+func (t *Throttler) Wait(ctx context.Context, key string) (time.Duration, error) {
+	if key == "" {
+		return 0, nil
+	}
+	until, err := t.store.LoginBlockedUntil(ctx, t.key(key))
+	if err != nil {
+		return 0, err
+	}
+	return max(time.Until(until), 0), nil
+}
+
+// Fail records a failed attempt and returns the block it caused, if any.
+func (t *Throttler) Fail(ctx context.Context, key string) (time.Duration, error) {
+	if key == "" {
+		return 0, nil
+	}
+	failures, err := t.store.RecordLoginFailure(ctx, t.key(key), t.policy.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	delay := t.policy.Delay(failures)
+	if delay == 0 {
+		return 0, nil
+	}
+	if err := t.store.BlockLogin(ctx, t.key(key), time.Now().Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+func (t *Throttler) Reset(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return t.store.ResetLoginFailures(ctx, t.key(key))
+}
+
+// Purge drops stale counters of this throttler, they are equivalent to none.
+func (t *Throttler) Purge(ctx context.Context) (int64, error) {
+	return t.store.PurgeLoginFailures(ctx, t.key(""), t.policy.Window)
+}
+
+func (t *Throttler) key(key string) string {
+	return t.name + ":" + key
+}
@@ -0,0 +1,143 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	if got := (Policy{BaseDelay: time.Minute, MaxDelay: time.Second}).Delay(0); got != time.Second {
+		t.Errorf("Delay above MaxDelay = %v, want %v", got, time.Second)
+	}
+}
+
+type memoryStore struct {
+	failures map[string]int
+	blocked  map[string]time.Time
+	err      error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{failures: make(map[string]int), blocked: make(map[string]time.Time)}
+}
+
+func (s *memoryStore) LoginBlockedUntil(_ context.Context, key string) (time.Time, error) {
+	return s.blocked[key], s.err
+}
+
+func (s *memoryStore) RecordLoginFailure(_ context.Context, key string, _ time.Duration) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.failures[key]++
+	return s.failures[key], nil
+}
+
+func (s *memoryStore) BlockLogin(_ context.Context, key string, until time.Time) error {
+	s.blocked[key] = until
+	return s.err
+}
+
+func (s *memoryStore) PurgeLoginFailures(_ context.Context, _ string, _ time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) ResetLoginFailures(_ context.Context, key string) error {
+	delete(s.failures, key)
+	delete(s.blocked, key)
+	return s.err
+}
+
+func TestThrottler(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	policy := Policy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	accounts := New("account", store, policy)
+	addresses := New("ip", store, policy)
+
+	for i, want := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		delay, err := accounts.Fail(ctx, "user@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delay != want {
+			t.Errorf("failure %d: delay = %v, want %v", i+1, delay, want)
+		}
+	}
+
+	wait, err := accounts.Wait(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= time.Minute || wait > 2*time.Minute {
+		t.Errorf("Wait = %v, want about 2m", wait)
+	}
+
+	// Keys of another throttler are separate even when equal.
+	if wait, _ := addresses.Wait(ctx, "user@example.com"); wait != 0 {
+		t.Errorf("Wait of another throttler = %v, want 0", wait)
+	}
+	if wait, _ := accounts.Wait(ctx, "other@example.com"); wait != 0 {
+		t.Errorf("Wait of another key = %v, want 0", wait)
+	}
+
+	if err := accounts.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := accounts.Wait(ctx, "user@example.com"); wait != 0 {
+		t.Errorf("Wait after Reset = %v, want 0", wait)
+	}
+	if delay, _ := accounts.Fail(ctx, "user@example.com"); delay != 0 {
+		t.Errorf("first failure after Reset: delay = %v, want 0", delay)
+	}
+}
+
+func TestThrottlerEmptyKey(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	store.err = errors.New("store must not be called")
+	throttler := New("ip", store, Policy{BaseDelay: time.Minute, MaxDelay: time.Hour})
+
+	if wait, err := throttler.Wait(ctx, ""); wait != 0 || err != nil {
+		t.Errorf("Wait = %v, %v", wait, err)
+	}
+	if delay, err := throttler.Fail(ctx, ""); delay != 0 || err != nil {
+		t.Errorf("Fail = %v, %v", delay, err)
+	}
+	if err := throttler.Reset(ctx, ""); err != nil {
+		t.Errorf("Reset = %v", err)
+	}
+}
+
+func TestThrottlerExpiredBlock(t *testing.T) {
+	store := newMemoryStore()
+	store.blocked["ip:10.0.0.1"] = time.Now().Add(-time.Minute)
+	throttler := New("ip", store, Policy{})
+
+	if wait, err := throttler.Wait(context.Background(), "10.0.0.1"); wait != 0 || err != nil {
+		t.Errorf("Wait = %v, %v, want 0", wait, err)
+	}
+}
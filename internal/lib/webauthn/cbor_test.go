@@ -0,0 +1,170 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cborMap keeps the key order of encoded maps stable.
+type cborMap []cborPair
+
+type cborPair struct {
+	key, value any
+}
+
+// encodeCBOR is the encoding counterpart of decodeCBOR for building test
+// input.
+func encodeCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n <= 0xffffffff:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []any:
+		out := head(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := head(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	}
+	panic("encodeCBOR: unsupported type")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Mostly RFC 8949 appendix A.
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"190100", int64(256)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"40", []byte(nil)},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"80", []any{}},
+		{"83010203", []any{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+	for _, tt := range tests {
+		in, _ := hex.DecodeString(tt.in)
+		got, rest, err := decodeCBOR(in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if len(rest) != 0 {
+			t.Errorf("%s: %d bytes left", tt.in, len(rest))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCBORRest(t *testing.T) {
+	got, rest, err := decodeCBOR([]byte{0x01, 0x02, 0x03})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != int64(1) || string(rest) != "\x02\x03" {
+		t.Errorf("decodeCBOR = %v, rest %x", got, rest)
+	}
+}
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"truncated argument", "18"},
+		{"truncated bytes", "440102"},
+		{"truncated text", "636162"},
+		{"truncated array", "830102"},
+		{"truncated map", "a20102"},
+		{"indefinite length", "9f01ff"},
+		{"reserved additional info", "1c"},
+		{"uint over int64", "1bffffffffffffffff"},
+		{"negative over int64", "3bffffffffffffffff"},
+		{"array key", "a18001"},
+		{"float16", "f93c00"},
+		{"array longer than input", "9a00010000"},
+		{"too deep", strings.Repeat("81", maxCBORDepth+1) + "00"},
+	}
+	for _, tt := range tests {
+		in, err := hex.DecodeString(tt.in)
+		if err != nil {
+			t.Fatalf("%s: bad test input: %v", tt.name, err)
+		}
+		if _, _, err := decodeCBOR(in); !errors.Is(err, errInvalidCBOR) {
+			t.Errorf("%s: err = %v, want errInvalidCBOR", tt.name, err)
+		}
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	value := cborMap{
+		{"fmt", "none"},
+		{int64(-2), []byte{1, 2}},
+		{int64(300), []any{int64(-300), "x"}},
+	}
+	got, rest, err := decodeCBOR(encodeCBOR(value))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR: %v, %d bytes left", err, len(rest))
+	}
+	want := map[any]any{
+		"fmt":      "none",
+		int64(-2):  []byte{1, 2},
+		int64(300): []any{int64(-300), "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+}
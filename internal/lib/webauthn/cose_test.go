@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// testKey is a credential key pair with its COSE encoding.
+type testKey struct {
+	alg  int64
+	cose []byte
+	sign func(data []byte) []byte
+}
+
+func newES256Key(t *testing.T) testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := pub.Bytes()
+	return testKey{
+		alg:  AlgES256,
+		cose: coseEC2(coseCrvP256, point[1:33], point[33:]),
+		sign: func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		},
+	}
+}
+
+func newEdDSAKey(t *testing.T) testKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{
+		alg: AlgEdDSA,
+		cose: encodeCBOR(cborMap{
+			{int64(coseKeyType), int64(coseKtyOKP)},
+			{int64(coseAlg), AlgEdDSA},
+			{int64(-1), int64(coseCrvEd25519)},
+			{int64(-2), []byte(pub)},
+		}),
+		sign: func(data []byte) []byte { return ed25519.Sign(priv, data) },
+	}
+}
+
+func newRS256Key(t *testing.T, bits int) testKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{
+		alg: AlgRS256,
+		cose: encodeCBOR(cborMap{
+			{int64(coseKeyType), int64(coseKtyRSA)},
+			{int64(coseAlg), AlgRS256},
+			{int64(-1), priv.N.Bytes()},
+			{int64(-2), big.NewInt(int64(priv.E)).Bytes()},
+		}),
+		sign: func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		},
+	}
+}
+
+func coseEC2(crv int64, x, y []byte) []byte {
+	return encodeCBOR(cborMap{
+		{int64(coseKeyType), int64(coseKtyEC2)},
+		{int64(coseAlg), AlgES256},
+		{int64(-1), crv},
+		{int64(-2), x},
+		{int64(-3), y},
+	})
+}
+
+func TestParsePublicKeyVerify(t *testing.T) {
+	keys := map[string]testKey{
+		"ES256": newES256Key(t),
+		"EdDSA": newEdDSAKey(t),
+		"RS256": newRS256Key(t, minRSABits),
+	}
+	data := []byte("signed data")
+
+	for name, k := range keys {
+		t.Run(name, func(t *testing.T) {
+			key, err := parsePublicKey(k.cose)
+			if err != nil {
+				t.Fatalf("parsePublicKey: %v", err)
+			}
+			if key.alg != k.alg {
+				t.Errorf("alg = %d, want %d", key.alg, k.alg)
+			}
+
+			sig := k.sign(data)
+			if err := key.verify(data, sig); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+			if err := key.verify([]byte("other data"), sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verify of other data: err = %v, want ErrInvalidSignature", err)
+			}
+			sig[len(sig)-1] ^= 1
+			if err := key.verify(data, sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verify of altered signature: err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyInvalid(t *testing.T) {
+	es256 := newES256Key(t)
+	point := make([]byte, 32)
+	point[31] = 1
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"empty", nil},
+		{"not a map", encodeCBOR([]any{int64(1)})},
+		{"trailing bytes", append(es256.cose, 0x00)},
+		{"unknown key type", encodeCBOR(cborMap{{int64(coseKeyType), int64(4)}, {int64(coseAlg), AlgES256}})},
+		{"mismatched algorithm", encodeCBOR(cborMap{{int64(coseKeyType), int64(coseKtyEC2)}, {int64(coseAlg), AlgEdDSA}})},
+		{"unsupported algorithm", encodeCBOR(cborMap{{int64(coseKeyType), int64(coseKtyEC2)}, {int64(coseAlg), int64(-35)}})},
+		{"other curve", coseEC2(2, point, point)},
+		{"short coordinate", coseEC2(coseCrvP256, point[1:], point)},
+		{"point off the curve", coseEC2(coseCrvP256, point, point)},
+		{"short ed25519 key", encodeCBOR(cborMap{
+			{int64(coseKeyType), int64(coseKtyOKP)},
+			{int64(coseAlg), AlgEdDSA},
+			{int64(-1), int64(coseCrvEd25519)},
+			{int64(-2), make([]byte, 31)},
+		})},
+		{"small rsa key", newRS256Key(t, 1024).cose},
+		{"rsa without exponent", encodeCBOR(cborMap{
+			{int64(coseKeyType), int64(coseKtyRSA)},
+			{int64(coseAlg), AlgRS256},
+			{int64(-1), make([]byte, 256)},
+		})},
+	}
+	for _, tt := range tests {
+		if _, err := parsePublicKey(tt.raw); !errors.Is(err, ErrUnsupportedKey) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedKey", tt.name, err)
+		}
+	}
+}
+
+func TestVerifySignatureKeyMismatch(t *testing.T) {
+	ed := newEdDSAKey(t)
+	key, err := parsePublicKey(ed.cose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("data")
+	if err := verifySignature(AlgES256, key.key, data, ed.sign(data)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("err = %v, want ErrInvalidSignature", err)
+	}
+}
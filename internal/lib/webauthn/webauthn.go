@@ -0,0 +1,406 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidClientData  = errors.New("invalid client data")
+	ErrInvalidAuthData    = errors.New("invalid authenticator data")
+	ErrInvalidAttestation = errors.New("invalid attestation")
+	ErrUnsupportedFormat  = errors.New("unsupported attestation format")
+	ErrUnsupportedKey     = errors.New("unsupported credential public key")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrSignCount          = errors.New("sign counter did not increase")
+)
+
+const (
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+
+	challengeSize = 32
+
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+
+	clientDataCreate = "webauthn.create"
+	clientDataGet    = "webauthn.get"
+)
+
+// oidAAGUID is the FIDO extension holding the authenticator model id in
+// packed attestation certificates.
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+type Config struct {
+	RPID             string
+	RPName           string
+	Origins          []string
+	UserVerification string
+}
+
+// RelyingParty builds ceremony options for browsers and verifies the
+// authenticator responses for a single RP ID.
+type RelyingParty struct {
+	cfg    Config
+	rpHash [32]byte
+}
+
+// Credential is what has to be stored after registration to verify later
+// assertions. PublicKey is kept in its COSE encoding.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+func New(cfg Config) *RelyingParty {
+	if cfg.UserVerification == "" {
+		cfg.UserVerification = UserVerificationRequired
+	}
+	return &RelyingParty{cfg: cfg, rpHash: sha256.Sum256([]byte(cfg.RPID))}
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type credentialParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CreationOptions returns PublicKeyCredentialCreationOptionsJSON for
+// navigator.credentials.create. Binary values are base64url encoded.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte, timeout time.Duration) ([]byte, error) {
+	type rpEntity struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	type userEntity struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	}
+	type selection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	}
+
+	return json.Marshal(struct {
+		RP                     rpEntity               `json:"rp"`
+		User                   userEntity             `json:"user"`
+		Challenge              string                 `json:"challenge"`
+		PubKeyCredParams       []credentialParam      `json:"pubKeyCredParams"`
+		Timeout                int64                  `json:"timeout"`
+		ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+		AuthenticatorSelection selection              `json:"authenticatorSelection"`
+		Attestation            string                 `json:"attestation"`
+	}{
+		RP: rpEntity{ID: rp.cfg.RPID, Name: rp.cfg.RPName},
+		User: userEntity{
+			ID:          encode(user.ID),
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+		},
+		Challenge: encode(challenge),
+		PubKeyCredParams: []credentialParam{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: selection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   rp.cfg.UserVerification,
+		},
+		Attestation: "none",
+	})
+}
+
+// RequestOptions returns PublicKeyCredentialRequestOptionsJSON for
+// navigator.credentials.get. An empty allow list lets the browser offer any
+// discoverable credential of the RP.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte, timeout time.Duration) ([]byte, error) {
+	return json.Marshal(struct {
+		Challenge        string                 `json:"challenge"`
+		Timeout          int64                  `json:"timeout"`
+		RPID             string                 `json:"rpId"`
+		AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+		UserVerification string                 `json:"userVerification"`
+	}{
+		Challenge:        encode(challenge),
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: rp.cfg.UserVerification,
+	})
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Challenge extracts the challenge from clientDataJSON so the caller can look
+// up the ceremony it belongs to before verifying the response.
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, ErrInvalidClientData
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, ErrInvalidClientData
+	}
+	return challenge, nil
+}
+
+// VerifyRegistration checks an attestation response against the issued
+// challenge and returns the new credential. "none" and "packed" (self and
+// x5c) attestation formats are accepted; certificate chains are not checked
+// against any metadata service.
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, clientDataJSON []byte, attestationObject []byte) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, clientDataCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrInvalidAttestation
+	}
+	object, ok := value.(map[any]any)
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	format, _ := object["fmt"].(string)
+	authData, _ := object["authData"].([]byte)
+	stmt, _ := object["attStmt"].(map[any]any)
+	if stmt == nil {
+		return nil, ErrInvalidAttestation
+	}
+
+	parsed, err := rp.parseAuthData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.flags&flagAttestedCredData == 0 || parsed.credential == nil {
+		return nil, ErrInvalidAuthData
+	}
+	key, err := parsePublicKey(parsed.credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := slices.Concat(authData, clientDataHash[:])
+
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return nil, ErrInvalidAttestation
+		}
+	case "packed":
+		if err := verifyPacked(stmt, signed, key, parsed.credential.AAGUID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	parsed.credential.SignCount = parsed.signCount
+	return parsed.credential, nil
+}
+
+// Assertion is the outcome of a verified login ceremony.
+type Assertion struct {
+	SignCount uint32
+	// UserVerified reports that the authenticator checked the user with a
+	// PIN or biometrics, not only their presence.
+	UserVerified bool
+}
+
+// VerifyAssertion checks an assertion made with a stored credential and
+// returns its new sign counter. A counter that does not grow means the
+// authenticator may have been cloned and is reported as ErrSignCount.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, credential Credential, clientDataJSON []byte, authenticatorData []byte, signature []byte) (*Assertion, error) {
+	if err := rp.verifyClientData(clientDataJSON, clientDataGet, challenge); err != nil {
+		return nil, err
+	}
+
+	parsed, err := rp.parseAuthData(authenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.verify(slices.Concat(authenticatorData, clientDataHash[:]), signature); err != nil {
+		return nil, err
+	}
+
+	if (parsed.signCount != 0 || credential.SignCount != 0) && parsed.signCount <= credential.SignCount {
+		return nil, ErrSignCount
+	}
+
+	return &Assertion{
+		SignCount:    parsed.signCount,
+		UserVerified: parsed.flags&flagUserVerified != 0,
+	}, nil
+}
+
+func (rp *RelyingParty) verifyClientData(clientDataJSON []byte, typ string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return ErrInvalidClientData
+	}
+	if data.Type != typ || data.CrossOrigin || !slices.Contains(rp.cfg.Origins, data.Origin) {
+		return ErrInvalidClientData
+	}
+	got, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrInvalidClientData
+	}
+	return nil
+}
+
+type authData struct {
+	flags      byte
+	signCount  uint32
+	credential *Credential
+}
+
+func (rp *RelyingParty) parseAuthData(data []byte) (*authData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidAuthData
+	}
+	if subtle.ConstantTimeCompare(data[:32], rp.rpHash[:]) != 1 {
+		return nil, ErrInvalidAuthData
+	}
+
+	parsed := &authData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if parsed.flags&flagUserPresent == 0 {
+		return nil, ErrInvalidAuthData
+	}
+	if rp.cfg.UserVerification == UserVerificationRequired && parsed.flags&flagUserVerified == 0 {
+		return nil, ErrInvalidAuthData
+	}
+
+	if parsed.flags&flagAttestedCredData == 0 {
+		return parsed, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidAuthData
+	}
+	aaguid := rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return nil, ErrInvalidAuthData
+	}
+	id := rest[:idLen]
+	rest = rest[idLen:]
+
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ErrInvalidAuthData
+	}
+	parsed.credential = &Credential{
+		ID:        bytes.Clone(id),
+		PublicKey: bytes.Clone(rest[:len(rest)-len(after)]),
+		AAGUID:    bytes.Clone(aaguid),
+	}
+
+	return parsed, nil
+}
+
+func verifyPacked(stmt map[any]any, signed []byte, credentialKey *publicKey, aaguid []byte) error {
+	alg, _ := stmt["alg"].(int64)
+	sig, _ := stmt["sig"].([]byte)
+	if len(sig) == 0 {
+		return ErrInvalidAttestation
+	}
+
+	chain, ok := stmt["x5c"].([]any)
+	if !ok {
+		if alg != credentialKey.alg {
+			return ErrInvalidAttestation
+		}
+		return credentialKey.verify(signed, sig)
+	}
+
+	if len(chain) == 0 {
+		return ErrInvalidAttestation
+	}
+	raw, _ := chain[0].([]byte)
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return ErrInvalidAttestation
+	}
+	if cert.Version != 3 || cert.IsCA {
+		return ErrInvalidAttestation
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(ext.Value, &value); err != nil || !bytes.Equal(value, aaguid) {
+			return ErrInvalidAttestation
+		}
+	}
+
+	return verifySignature(alg, cert.PublicKey, signed, sig)
+}
+
+func descriptors(ids [][]byte) []credentialDescriptor {
+	list := make([]credentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, credentialDescriptor{Type: "public-key", ID: encode(id)})
+	}
+	return list
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,288 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func newTestRP(userVerification string) *RelyingParty {
+	return New(Config{
+		RPID:             testRPID,
+		RPName:           "Example",
+		Origins:          []string{testOrigin},
+		UserVerification: userVerification,
+	})
+}
+
+// makeAuthData builds authenticator data for the test RP. A non-nil
+// credential ID adds attested credential data with the COSE key.
+func makeAuthData(flags byte, signCount uint32, credentialID []byte, cose []byte) []byte {
+	rpHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if credentialID == nil {
+		return data
+	}
+	data = append(data, bytes.Repeat([]byte{0xaa}, 16)...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(credentialID)))
+	data = append(data, credentialID...)
+	return append(data, cose...)
+}
+
+func makeClientData(typ string, challenge []byte, origin string, crossOrigin bool) []byte {
+	data, _ := json.Marshal(clientData{
+		Type:        typ,
+		Challenge:   base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:      origin,
+		CrossOrigin: crossOrigin,
+	})
+	return data
+}
+
+func TestParseAuthData(t *testing.T) {
+	key := newEdDSAKey(t)
+	id := []byte{1, 2, 3, 4}
+
+	rp := newTestRP(UserVerificationPreferred)
+	parsed, err := rp.parseAuthData(makeAuthData(flagUserPresent|flagAttestedCredData, 7, id, key.cose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.signCount != 7 {
+		t.Errorf("signCount = %d, want 7", parsed.signCount)
+	}
+	if parsed.credential == nil {
+		t.Fatal("no credential")
+	}
+	if !bytes.Equal(parsed.credential.ID, id) || !bytes.Equal(parsed.credential.PublicKey, key.cose) {
+		t.Errorf("credential = %x / %x, want %x / %x", parsed.credential.ID, parsed.credential.PublicKey, id, key.cose)
+	}
+	if !bytes.Equal(parsed.credential.AAGUID, bytes.Repeat([]byte{0xaa}, 16)) {
+		t.Errorf("AAGUID = %x", parsed.credential.AAGUID)
+	}
+
+	parsed, err = rp.parseAuthData(makeAuthData(flagUserPresent, 1, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.credential != nil {
+		t.Error("credential parsed without the attested data flag")
+	}
+}
+
+func TestParseAuthDataInvalid(t *testing.T) {
+	key := newEdDSAKey(t)
+	valid := makeAuthData(flagUserPresent|flagAttestedCredData, 0, []byte{1}, key.cose)
+	otherRP := slices.Clone(valid)
+	otherRP[0] ^= 1
+
+	tests := []struct {
+		name             string
+		userVerification string
+		data             []byte
+	}{
+		{"short", UserVerificationPreferred, valid[:36]},
+		{"other rp", UserVerificationPreferred, otherRP},
+		{"user not present", UserVerificationPreferred, makeAuthData(0, 0, nil, nil)},
+		{"user not verified", UserVerificationRequired, makeAuthData(flagUserPresent, 0, nil, nil)},
+		{"no credential data", UserVerificationPreferred, valid[:37+10]},
+		{"truncated credential id", UserVerificationPreferred, makeAuthData(flagUserPresent|flagAttestedCredData, 0, []byte{1}, nil)[:37+18]},
+		{"invalid key", UserVerificationPreferred, makeAuthData(flagUserPresent|flagAttestedCredData, 0, []byte{1}, []byte{0x18})},
+	}
+	for _, tt := range tests {
+		if _, err := newTestRP(tt.userVerification).parseAuthData(tt.data); !errors.Is(err, ErrInvalidAuthData) {
+			t.Errorf("%s: err = %v, want ErrInvalidAuthData", tt.name, err)
+		}
+	}
+
+	verified := makeAuthData(flagUserPresent|flagUserVerified, 0, nil, nil)
+	if _, err := newTestRP(UserVerificationRequired).parseAuthData(verified); err != nil {
+		t.Errorf("verified user rejected: %v", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	challenge := []byte("challenge")
+	got, err := Challenge(makeClientData(clientDataGet, challenge, testOrigin, false))
+	if err != nil || !bytes.Equal(got, challenge) {
+		t.Errorf("Challenge = %q, %v", got, err)
+	}
+
+	for _, raw := range []string{`not json`, `{"challenge":""}`, `{"challenge":"***"}`} {
+		if _, err := Challenge([]byte(raw)); !errors.Is(err, ErrInvalidClientData) {
+			t.Errorf("%s: err = %v, want ErrInvalidClientData", raw, err)
+		}
+	}
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	rp := newTestRP(UserVerificationPreferred)
+	key := newES256Key(t)
+	challenge := []byte("registration challenge")
+	id := []byte("credential id")
+	clientDataJSON := makeClientData(clientDataCreate, challenge, testOrigin, false)
+	authData := makeAuthData(flagUserPresent|flagAttestedCredData, 3, id, key.cose)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	selfSig := key.sign(slices.Concat(authData, clientDataHash[:]))
+
+	attestation := func(format string, stmt cborMap) []byte {
+		return encodeCBOR(cborMap{{"fmt", format}, {"attStmt", stmt}, {"authData", authData}})
+	}
+
+	tests := []struct {
+		name    string
+		object  []byte
+		wantErr error
+	}{
+		{"none", attestation("none", cborMap{}), nil},
+		{"packed self", attestation("packed", cborMap{{"alg", AlgES256}, {"sig", selfSig}}), nil},
+		{"none with statement", attestation("none", cborMap{{"alg", AlgES256}}), ErrInvalidAttestation},
+		{"packed other alg", attestation("packed", cborMap{{"alg", AlgEdDSA}, {"sig", selfSig}}), ErrInvalidAttestation},
+		{"packed bad signature", attestation("packed", cborMap{{"alg", AlgES256}, {"sig", []byte{1}}}), ErrInvalidSignature},
+		{"packed empty chain", attestation("packed", cborMap{{"alg", AlgES256}, {"sig", selfSig}, {"x5c", []any{}}}), ErrInvalidAttestation},
+		{"unknown format", attestation("tpm", cborMap{}), ErrUnsupportedFormat},
+		{"no statement", encodeCBOR(cborMap{{"fmt", "none"}, {"authData", authData}}), ErrInvalidAttestation},
+		{"not cbor", []byte{0xff}, ErrInvalidAttestation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential, err := rp.VerifyRegistration(challenge, clientDataJSON, tt.object)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(credential.ID, id) || !bytes.Equal(credential.PublicKey, key.cose) || credential.SignCount != 3 {
+				t.Errorf("credential = %+v", credential)
+			}
+		})
+	}
+}
+
+func TestVerifyClientData(t *testing.T) {
+	rp := newTestRP(UserVerificationPreferred)
+	challenge := []byte("challenge")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", makeClientData(clientDataGet, challenge, testOrigin, false), false},
+		{"other type", makeClientData(clientDataCreate, challenge, testOrigin, false), true},
+		{"other origin", makeClientData(clientDataGet, challenge, "https://evil.example", false), true},
+		{"cross origin", makeClientData(clientDataGet, challenge, testOrigin, true), true},
+		{"other challenge", makeClientData(clientDataGet, []byte("other"), testOrigin, false), true},
+		{"not json", []byte("{"), true},
+	}
+	for _, tt := range tests {
+		err := rp.verifyClientData(tt.data, clientDataGet, challenge)
+		if tt.wantErr && !errors.Is(err, ErrInvalidClientData) {
+			t.Errorf("%s: err = %v, want ErrInvalidClientData", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newTestRP(UserVerificationPreferred)
+	key := newEdDSAKey(t)
+	challenge := []byte("login challenge")
+	clientDataJSON := makeClientData(clientDataGet, challenge, testOrigin, false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	tests := []struct {
+		name      string
+		stored    uint32
+		signCount uint32
+		flags     byte
+		want      uint32
+		wantUV    bool
+		wantErr   error
+	}{
+		{"no counter", 0, 0, flagUserPresent, 0, false, nil},
+		{"first use", 0, 1, flagUserPresent, 1, false, nil},
+		{"counter grows", 5, 9, flagUserPresent, 9, false, nil},
+		{"user verified", 0, 1, flagUserPresent | flagUserVerified, 1, true, nil},
+		{"counter repeats", 5, 5, flagUserPresent, 0, false, ErrSignCount},
+		{"counter goes back", 5, 4, flagUserPresent, 0, false, ErrSignCount},
+		{"counter reset", 5, 0, flagUserPresent, 0, false, ErrSignCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authData := makeAuthData(tt.flags, tt.signCount, nil, nil)
+			sig := key.sign(slices.Concat(authData, clientDataHash[:]))
+			credential := Credential{ID: []byte{1}, PublicKey: key.cose, SignCount: tt.stored}
+
+			got, err := rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, sig)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAssertion err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.SignCount != tt.want || got.UserVerified != tt.wantUV) {
+				t.Errorf("VerifyAssertion = %+v, want sign count %d, user verified %v", got, tt.want, tt.wantUV)
+			}
+		})
+	}
+
+	authData := makeAuthData(flagUserPresent, 1, nil, nil)
+	credential := Credential{ID: []byte{1}, PublicKey: key.cose}
+	other := newEdDSAKey(t)
+	sig := other.sign(slices.Concat(authData, clientDataHash[:]))
+	if _, err := rp.VerifyAssertion(challenge, credential, clientDataJSON, authData, sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature of another key: err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	rp := newTestRP("")
+	challenge := []byte{1, 2, 3}
+
+	raw, err := rp.RequestOptions(challenge, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request struct {
+		Challenge        string `json:"challenge"`
+		RPID             string `json:"rpId"`
+		AllowCredentials []any  `json:"allowCredentials"`
+		UserVerification string `json:"userVerification"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		t.Fatal(err)
+	}
+	if request.Challenge != "AQID" || request.RPID != testRPID || request.AllowCredentials == nil ||
+		request.UserVerification != UserVerificationRequired {
+		t.Errorf("RequestOptions = %s", raw)
+	}
+
+	raw, err = rp.CreationOptions(challenge, User{ID: []byte{9}, Name: "user@example.com"}, [][]byte{{7}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var creation struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		ExcludeCredentials []credentialDescriptor `json:"excludeCredentials"`
+	}
+	if err := json.Unmarshal(raw, &creation); err != nil {
+		t.Fatal(err)
+	}
+	if creation.User.ID != "CQ" || len(creation.ExcludeCredentials) != 1 || creation.ExcludeCredentials[0].ID != "Bw" {
+		t.Errorf("CreationOptions = %s", raw)
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DeleteAccount soft deletes the token owner after re-checking the password.
+// The account is purged for good once the retention period has passed.
+func (auth *Auth) DeleteAccount(ctx context.Context, token string, password string) error {
+	const op = "auth.DeleteAccount"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := auth.confirmPassword(ctx, log, user, password); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.SoftDeleteUser(ctx, user.ID); err != nil {
+		log.Error("failed to delete user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{UserID: user.ID, Event: models.EventAccountDeleted}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	log.Info("account deleted", slog.String("user_id", user.ID))
+	return nil
+}
+
+// PurgeDeletedAccounts removes accounts whose retention period has passed.
+func (auth *Auth) PurgeDeletedAccounts(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeDeletedAccounts"
+
+	purged, err := auth.usrSaver.PurgeDeletedUsers(ctx, time.Now().Add(-auth.deletionRetention))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return purged, nil
+}
+
+type exportedUser struct {
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+type exportedSession struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	ClientIP   string    `json:"client_ip"`
+	DeviceName string    `json:"device_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type exportedEvent struct {
+	Event     string            `json:"event"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type dataExport struct {
+	ExportedAt  time.Time         `json:"exported_at"`
+	User        exportedUser      `json:"user"`
+	Sessions    []exportedSession `json:"sessions"`
+	AuditEvents []exportedEvent   `json:"audit_events"`
+}
+
+// ExportMyData returns everything stored about the token owner as JSON.
+func (auth *Auth) ExportMyData(ctx context.Context, token string) ([]byte, error) {
+	const op = "auth.ExportMyData"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	sessions, err := auth.usrProvider.UserSessions(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get sessions", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := auth.usrProvider.UserAuditEvents(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get audit events", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	export := dataExport{
+		ExportedAt: time.Now().UTC(),
+		User: exportedUser{
+			ID:              user.ID,
+			Email:           user.Email,
+			Name:            user.Name,
+			CreatedAt:       user.CreatedAt,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+		},
+		Sessions:    make([]exportedSession, 0, len(sessions)),
+		AuditEvents: make([]exportedEvent, 0, len(events)),
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, exportedSession(session))
+	}
+	for _, event := range events {
+		export.AuditEvents = append(export.AuditEvents, exportedEvent{
+			Event:     event.Event,
+			Metadata:  event.Metadata,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Error("failed to encode export", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("data exported", slog.String("user_id", user.ID))
+	return data, nil
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if err := auth.DeleteAccount(ctx, user.Token, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("DeleteAccount with a wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := auth.DeleteAccount(ctx, user.Token, "secret"); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	if got := store.eventCount(models.EventAccountDeleted); got != 1 {
+		t.Errorf("deletion events = %d, want 1", got)
+	}
+
+	if _, err := auth.GetUser(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser after deletion: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh after deletion: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login after deletion: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := auth.DeleteAccount(ctx, user.Token, "secret"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("DeleteAccount twice: err = %v, want ErrInvalidToken", err)
+	}
+
+	// The address is free again while the deleted account awaits its purge.
+	again := register(t, auth, "user@example.com", "new secret")
+	if again.ID == user.ID {
+		t.Error("registration revived the deleted account")
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "new secret", ""); err != nil {
+		t.Errorf("Login to the new account: %v", err)
+	}
+}
+
+func TestDeleteAccountFailures(t *testing.T) {
+	ctx := context.Background()
+
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	for range 3 {
+		if err := auth.DeleteAccount(ctx, user.Token, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("DeleteAccount with a wrong password: err = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if err := auth.DeleteAccount(ctx, user.Token, "secret"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("DeleteAccount after failures: err = %v, want ErrTooManyAttempts", err)
+	}
+
+	auth, _ = newTestAuth(t, withLockout)
+	user = register(t, auth, "user@example.com", "secret")
+	for range 3 {
+		auth.DeleteAccount(ctx, user.Token, "wrong")
+	}
+	if err := auth.DeleteAccount(ctx, user.Token, "secret"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("DeleteAccount after the lockout threshold: err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, func(cfg *Config) { cfg.DeletionRetention = time.Hour })
+	old := register(t, auth, "old@example.com", "secret")
+	recent := register(t, auth, "recent@example.com", "secret")
+	register(t, auth, "active@example.com", "secret")
+
+	for _, user := range []string{old.Token, recent.Token} {
+		if err := auth.DeleteAccount(ctx, user, "secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.deleted[old.ID] = time.Now().Add(-2 * time.Hour)
+
+	purged, err := auth.PurgeDeletedAccounts(ctx)
+	if err != nil {
+		t.Fatalf("PurgeDeletedAccounts: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged = %d, want 1", purged)
+	}
+	if _, ok := store.users[old.ID]; ok {
+		t.Error("account past retention was kept")
+	}
+	if _, ok := store.users[recent.ID]; !ok {
+		t.Error("account within retention was purged")
+	}
+}
+
+func TestExportMyData(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := auth.ChangePassword(ctx, user.Token, "secret", "new secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := auth.ExportMyData(ctx, tokens.Token)
+	if err != nil {
+		t.Fatalf("ExportMyData: %v", err)
+	}
+	var export struct {
+		User struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"user"`
+		Sessions    []json.RawMessage `json:"sessions"`
+		AuditEvents []struct {
+			Event string `json:"event"`
+		} `json:"audit_events"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	if export.User.ID != user.ID || export.User.Email != "user@example.com" {
+		t.Errorf("user = %+v", export.User)
+	}
+	if len(export.Sessions) != 1 {
+		t.Errorf("sessions = %d, want 1", len(export.Sessions))
+	}
+	if len(export.AuditEvents) != 1 || export.AuditEvents[0].Event != models.EventPasswordChanged {
+		t.Errorf("audit events = %+v", export.AuditEvents)
+	}
+	if _, err := auth.ExportMyData(ctx, user.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ExportMyData with a stale token: err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -2,138 +2,468 @@ package auth
 
 import (
 	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/clientinfo"
+	"auth-api/internal/lib/displayname"
+	"auth-api/internal/lib/emailaddr"
 	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/passhash"
+	"auth-api/internal/lib/password"
+	"auth-api/internal/lib/secretbox"
+	"auth-api/internal/lib/throttle"
+	"auth-api/internal/lib/webauthn"
 	"auth-api/internal/storage"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
-	log           *slog.Logger
-	usrSaver      UserSaver
-	usrProvider   UserProvider
-	accessTTL     time.Duration
-	accessSecret  string
-	refreshTTL    time.Duration
-	refreshSecret string
+	log                  *slog.Logger
+	usrSaver             UserSaver
+	usrProvider          UserProvider
+	revoker              TokenRevoker
+	mailer               Mailer
+	accessTTL            time.Duration
+	accessKeys           *jwt.KeyRing
+	refreshTTL           time.Duration
+	refreshKeys          *jwt.KeyRing
+	issuer               string
+	audiences            []string
+	verificationTTL      time.Duration
+	verificationURL      string
+	emailChangeURL       string
+	requireVerifiedEmail bool
+	passwordResetTTL     time.Duration
+	passwordResetURL     string
+	deletionRetention    time.Duration
+	secretBox            *secretbox.Box
+	totpIssuer           string
+	mfaChallengeTTL      time.Duration
+	webAuthn             *webauthn.RelyingParty
+	passkeyChallengeTTL  time.Duration
+	accountThrottle      *throttle.Throttler
+	ipThrottle           *throttle.Throttler
+	lockoutThreshold     int
+	lockoutDuration      time.Duration
+	adminKey             string
+	introspectionClients map[string]string
+	passwordPolicy       *password.Policy
+	breachChecker        BreachChecker
+	breachFailOpen       bool
+	hasher               *passhash.Hasher
+	emails               *emailaddr.Normalizer
+	names                *displayname.Policy
+
+	// background tracks work finished after the call returned.
+	background sync.WaitGroup
+}
+
+// backgroundTimeout bounds work started by a call and finished after it.
+const backgroundTimeout = 30 * time.Second
+
+type Config struct {
+	AccessTTL   time.Duration
+	AccessKeys  *jwt.KeyRing
+	RefreshTTL  time.Duration
+	RefreshKeys *jwt.KeyRing
+	Issuer      string
+	Audiences   []string
+
+	VerificationTTL time.Duration
+	// VerificationURL is a format string receiving the verification token.
+	VerificationURL string
+	// EmailChangeURL is a format string receiving the email change token.
+	EmailChangeURL       string
+	RequireVerifiedEmail bool
+
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is a format string receiving the reset token.
+	PasswordResetURL string
+
+	// DeletionRetention is how long soft deleted accounts are kept.
+	DeletionRetention time.Duration
+
+	// SecretBox encrypts TOTP secrets at rest, nil disables TOTP.
+	SecretBox       *secretbox.Box
+	TOTPIssuer      string
+	MFAChallengeTTL time.Duration
+
+	WebAuthn            *webauthn.RelyingParty
+	PasskeyChallengeTTL time.Duration
+
+	// AccountThrottle and IPThrottle slow down repeated failed logins per
+	// email and per client address.
+	AccountThrottle *throttle.Throttler
+	IPThrottle      *throttle.Throttler
+
+	// LockoutThreshold consecutive wrong passwords lock the account for
+	// LockoutDuration. Zero disables the lockout.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// AdminKey authorizes admin calls. Empty disables them.
+	AdminKey string
+	// IntrospectionClients maps the client ids allowed to call Introspect to
+	// their secrets. Empty disables introspection.
+	IntrospectionClients map[string]string
+
+	PasswordPolicy *password.Policy
+	// BreachChecker rejects passwords known from data breaches, nil disables
+	// it. BreachFailOpen accepts passwords while the checker is failing.
+	BreachChecker  BreachChecker
+	BreachFailOpen bool
+
+	// PasswordHasher hashes new passwords. Stored hashes it would not
+	// produce are replaced on the next successful login.
+	PasswordHasher *passhash.Hasher
+
+	// Emails validates and normalizes addresses, nil keeps them as given.
+	Emails *emailaddr.Normalizer
+	// Names validates and normalizes display names, nil keeps them as given.
+	Names *displayname.Policy
 }
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidToken       = errors.New("invalid refresh token")
 	ErrAlreadyExist       = errors.New("user already")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrInvalidAudience    = errors.New("audience is not allowed")
+	ErrEmailNotVerified   = errors.New("email is not verified")
+	ErrMFAAlreadyEnabled  = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled     = errors.New("mfa is not enrolled")
+	ErrMFADisabled        = errors.New("mfa is disabled")
+	ErrInvalidMFACode     = errors.New("invalid mfa code")
+	ErrInvalidPasskey     = errors.New("invalid passkey")
+	ErrPasskeyExists      = errors.New("passkey already registered")
+	ErrTooManyAttempts    = errors.New("too many attempts")
+	ErrAccountLocked      = errors.New("account is locked")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidLockUntil   = errors.New("lock end is not in the future")
+	ErrBreachCheckFailed  = errors.New("breached password check failed")
 )
 
 type UserSaver interface {
-	CreateUser(ctx context.Context, name string, email string, passHash []byte) (*models.UserModel, error)
-	SaveRefreshToken(ctx context.Context, tokenID string, userID string, expiresAt time.Time) error
-	RemoveRefreshToken(ctx context.Context, tokenID string) error
+	CreateUser(ctx context.Context, name string, email string, passHash []byte, pepper int) (*models.UserModel, error)
+	SaveRefreshToken(ctx context.Context, token models.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, tokenID string) error
+	RemoveRefreshTokenFamily(ctx context.Context, familyID string) error
+	RemoveUserRefreshTokens(ctx context.Context, userID string) error
+	RemoveUserSession(ctx context.Context, userID string, sessionID string) error
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+	SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error
+	ConsumeEmailVerification(ctx context.Context, tokenHash []byte) (*models.EmailVerification, error)
+	MarkEmailVerified(ctx context.Context, userID string, email string) error
+	SavePasswordReset(ctx context.Context, reset models.PasswordReset) error
+	ConsumePasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error)
+	UpdatePassword(ctx context.Context, userID string, passHash []byte, pepper int) error
+	UpgradePasswordHash(ctx context.Context, userID string, oldHash []byte, newHash []byte, pepper int) error
+	UpdateUserName(ctx context.Context, userID string, name string) (*models.UserModel, error)
+	SaveEmailChange(ctx context.Context, change models.EmailChange) error
+	ConsumeEmailChange(ctx context.Context, tokenHash []byte) (*models.EmailChange, error)
+	UpdateUserEmail(ctx context.Context, userID string, email string) (*models.UserModel, error)
+	SoftDeleteUser(ctx context.Context, userID string) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	SaveTOTP(ctx context.Context, totp models.TOTP) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []models.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, codeID string) error
+	RecordFailedLogin(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) (*time.Time, error)
+	ResetFailedLogins(ctx context.Context, userID string) error
+	LockUser(ctx context.Context, userID string, until time.Time) error
+	UnlockUser(ctx context.Context, userID string) error
+	SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error
+	ConsumeWebAuthnChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error)
+	PurgeWebAuthnChallenges(ctx context.Context, expiredBefore time.Time) (int64, error)
+	SavePasskey(ctx context.Context, passkey models.Passkey) error
+	UpdatePasskeySignCount(ctx context.Context, credentialID []byte, signCount uint32) error
+}
+
+type TokenRevoker interface {
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	AccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg mailer.Message) error
+}
+
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (*models.UserModel, error)
 	UserByID(ctx context.Context, userID string) (*models.UserModel, error)
-	RefreshToken(ctx context.Context, tokenID string) (userID string, err error)
+	TokenVersion(ctx context.Context, userID string) (int, error)
+	RefreshToken(ctx context.Context, tokenID string) (*models.RefreshToken, error)
+	SessionActive(ctx context.Context, sessionID string) (bool, error)
+	UserSessions(ctx context.Context, userID string) ([]models.Session, error)
+	UserAuditEvents(ctx context.Context, userID string) ([]models.AuditEvent, error)
+	PasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error)
+	UserTOTP(ctx context.Context, userID string) (*models.TOTP, error)
+	UnusedRecoveryCodes(ctx context.Context, userID string) ([]models.RecoveryCode, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	Passkey(ctx context.Context, credentialID []byte) (*models.Passkey, error)
+	UserPasskeys(ctx context.Context, userID string) ([]models.Passkey, error)
 }
 
-func New(log *slog.Logger, userSaver UserSaver, userProvider UserProvider, accessTTL time.Duration, accessSecret string, refreshTTL time.Duration, refreshSecret string) *Auth {
+func New(log *slog.Logger, userSaver UserSaver, userProvider UserProvider, revoker TokenRevoker, mailer Mailer, cfg Config) *Auth {
 	return &Auth{
-		log:           log,
-		usrSaver:      userSaver,
-		usrProvider:   userProvider,
-		accessTTL:     accessTTL,
-		accessSecret:  accessSecret,
-		refreshTTL:    refreshTTL,
-		refreshSecret: refreshSecret,
+		log:                  log,
+		usrSaver:             userSaver,
+		usrProvider:          userProvider,
+		revoker:              revoker,
+		mailer:               mailer,
+		accessTTL:            cfg.AccessTTL,
+		accessKeys:           cfg.AccessKeys,
+		refreshTTL:           cfg.RefreshTTL,
+		refreshKeys:          cfg.RefreshKeys,
+		issuer:               cfg.Issuer,
+		audiences:            cfg.Audiences,
+		verificationTTL:      cfg.VerificationTTL,
+		verificationURL:      cfg.VerificationURL,
+		emailChangeURL:       cfg.EmailChangeURL,
+		requireVerifiedEmail: cfg.RequireVerifiedEmail,
+		passwordResetTTL:     cfg.PasswordResetTTL,
+		passwordResetURL:     cfg.PasswordResetURL,
+		deletionRetention:    cfg.DeletionRetention,
+		secretBox:            cfg.SecretBox,
+		totpIssuer:           cfg.TOTPIssuer,
+		mfaChallengeTTL:      cfg.MFAChallengeTTL,
+		webAuthn:             cfg.WebAuthn,
+		passkeyChallengeTTL:  cfg.PasskeyChallengeTTL,
+		accountThrottle:      cfg.AccountThrottle,
+		ipThrottle:           cfg.IPThrottle,
+		lockoutThreshold:     cfg.LockoutThreshold,
+		lockoutDuration:      cfg.LockoutDuration,
+		adminKey:             cfg.AdminKey,
+		introspectionClients: cfg.IntrospectionClients,
+		passwordPolicy:       cfg.PasswordPolicy,
+		breachChecker:        cfg.BreachChecker,
+		breachFailOpen:       cfg.BreachFailOpen,
+		hasher:               cfg.PasswordHasher,
+		emails:               cfg.Emails,
+		names:                cfg.Names,
 	}
 }
 
-func (auth *Auth) Register(ctx context.Context, name string, email string, password string) (*models.UserResponse, error) {
+// Wait blocks until background work, such as reset emails, has finished.
+func (auth *Auth) Wait() {
+	auth.background.Wait()
+}
+
+func (auth *Auth) Register(ctx context.Context, name string, email string, password string, audience string) (*models.UserResponse, error) {
 	const op = "auth.RegisterUser"
 
 	log := auth.log.With(slog.String("op", op))
 
+	audience, err := auth.audience(audience)
+	if err != nil {
+		log.Error("invalid audience", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	name, err = auth.normalizeName(name)
+	if err != nil {
+		log.Warn("name rejected", slog.String("reason", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, err = auth.newEmail(email)
+	if err != nil {
+		log.Warn("email rejected", slog.String("reason", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.checkPassword(ctx, password, email, name); err != nil {
+		log.Warn("password rejected by policy", slog.String("reason", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("Creating user")
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passHash, pepper, err := auth.hasher.Hash(password)
 	if err != nil {
-		log.Error("failed generate password hash", err)
+		log.Error("failed generate password hash", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	user, err := auth.usrSaver.CreateUser(ctx, name, email, passHash)
+	user, err := auth.usrSaver.CreateUser(ctx, name, email, passHash, pepper)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			log.Error("user already exists", err)
+			log.Error("user already exists", slog.String("error", err.Error()))
 			return nil, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
 
-		log.Error("failed to save user", err)
+		log.Error("failed to save user", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return auth.createTokens(ctx, user)
+	if err := auth.sendVerification(ctx, user); err != nil {
+		log.Error("failed to send verification email", slog.String("error", err.Error()))
+		if auth.requireVerifiedEmail {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if auth.requireVerifiedEmail {
+		return &models.UserResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+		}, nil
+	}
+
+	return auth.createTokens(ctx, user, audience, nil)
 }
 
-func (auth *Auth) Login(ctx context.Context, email string, password string) (*models.UserResponse, error) {
+func (auth *Auth) Login(ctx context.Context, email string, password string, audience string) (*models.UserResponse, error) {
 	const op = "auth.Login"
 
 	log := auth.log.With(slog.String("op", op))
 
+	audience, err := auth.audience(audience)
+	if err != nil {
+		log.Error("invalid audience", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email = auth.lookupEmail(email)
+	account, ip := throttleAccount(email), clientinfo.FromContext(ctx).IP
+	if err := auth.checkThrottle(ctx, account, ip); err != nil {
+		log.Warn("login throttled", slog.String("account", account), slog.String("ip", ip))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("Get user from db")
 	user, err := auth.usrProvider.User(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Error("user not found", err)
+			log.Error("user not found", slog.String("error", err.Error()))
+			auth.recordFailure(ctx, log, account, ip)
 			return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
-		log.Error("failed to get user", err)
+		log.Error("failed to get user", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		log.Error("invalid credentials", err)
+	if err := auth.hasher.Verify(user.PasswordHash, user.PepperVersion, password); err != nil {
+		if !errors.Is(err, passhash.ErrMismatch) {
+			// A broken hash or a missing pepper is not the user's fault
+			// and must not count against the account.
+			log.Error("failed to verify password", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("invalid credentials", slog.String("error", err.Error()))
+		auth.recordFailure(ctx, log, account, ip)
+		auth.recordFailedLogin(ctx, log, user.ID)
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
+	auth.resetFailures(ctx, log, account)
+	auth.upgradePasswordHash(ctx, log, user, password)
+
+	resp, err := auth.finishLogin(ctx, log, user, audience, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return resp, nil
+}
+
+// finishLogin runs the checks shared by every first factor once the user is
+// authenticated and issues either the token pair or an MFA challenge.
+// mfaSatisfied skips the challenge when the first factor already proved
+// a second one, such as a user verified passkey.
+func (auth *Auth) finishLogin(ctx context.Context, log *slog.Logger, user *models.UserModel, audience string, mfaSatisfied bool) (*models.UserResponse, error) {
+	if err := checkLocked(user); err != nil {
+		log.Warn("account is locked", slog.String("user_id", user.ID))
+		return nil, err
+	}
+	if user.FailedAttempts > 0 {
+		if err := auth.usrSaver.ResetFailedLogins(ctx, user.ID); err != nil {
+			log.Error("failed to reset failed logins", slog.String("error", err.Error()))
+		}
+	}
+
+	if auth.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		log.Warn("email is not verified", slog.String("user_id", user.ID))
+		return nil, ErrEmailNotVerified
+	}
+
+	if !mfaSatisfied {
+		mfaEnabled, err := auth.mfaEnabled(ctx, user.ID)
+		if err != nil {
+			log.Error("failed to check mfa", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if mfaEnabled {
+			mfaToken, err := jwt.NewMFAToken(user.ID, auth.refreshKeys, auth.issuer, audience, auth.mfaChallengeTTL)
+			if err != nil {
+				log.Error("failed to generate mfa token", slog.String("error", err.Error()))
+				return nil, err
+			}
+
+			log.Info("mfa challenge issued", slog.String("user_id", user.ID))
+			return &models.UserResponse{
+				ID:        user.ID,
+				Name:      user.Name,
+				Email:     user.Email,
+				CreatedAt: user.CreatedAt,
+				MFAToken:  mfaToken,
+			}, nil
+		}
+	}
 
 	log.Info("user logined")
-	return auth.createTokens(ctx, user)
+	return auth.createTokens(ctx, user, audience, nil)
 }
 
 func (auth *Auth) Refresh(ctx context.Context, refreshToken string) (*models.Refresh, error) {
 	const op = "auth.Refresh"
 	log := auth.log.With(slog.String("op", op))
 
-	claims, err := jwt.ParseRefreshToken(refreshToken, auth.refreshSecret)
+	claims, err := jwt.ParseRefreshToken(refreshToken, auth.refreshKeys, auth.issuer, auth.audiences)
 	if err != nil {
-		log.Error("invalid refresh token", err)
+		log.Error("invalid refresh token", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	userID, err := auth.usrProvider.RefreshToken(ctx, claims.TokenID)
+	stored, err := auth.usrProvider.RefreshToken(ctx, claims.ID)
 	if err != nil {
-		log.Error("refresh token not found", err)
+		log.Error("refresh token not found", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if stored.RotatedAt != nil {
+		auth.revokeReusedFamily(ctx, log, stored)
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	user, err := auth.usrProvider.UserByID(ctx, userID)
+	user, err := auth.usrProvider.UserByID(ctx, stored.UserID)
 	if err != nil {
-		log.Error("user not found", err)
+		log.Error("user not found", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	if err := auth.usrSaver.RemoveRefreshToken(ctx, claims.TokenID); err != nil {
-		log.Error("failed to remove old refresh token", err)
+	if err := auth.usrSaver.RotateRefreshToken(ctx, stored.TokenID); err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			// Another request rotated the token after it was read: the same
+			// reuse as above, only concurrent.
+			auth.revokeReusedFamily(ctx, log, stored)
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to rotate refresh token", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	data, err := auth.createTokens(ctx, user)
+	data, err := auth.createTokens(ctx, user, claims.Audience[0], stored)
 	if err != nil {
-		log.Error("failed to generate tokens", err)
+		log.Error("failed to generate tokens", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return &models.Refresh{
@@ -142,20 +472,91 @@ func (auth *Auth) Refresh(ctx context.Context, refreshToken string) (*models.Ref
 	}, nil
 }
 
-func (auth *Auth) GetUser(ctx context.Context, token string) (*models.UserInfo, error) {
+func (auth *Auth) Logout(ctx context.Context, refreshToken string, accessToken string) error {
+	const op = "auth.Logout"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := jwt.ParseRefreshToken(refreshToken, auth.refreshKeys, auth.issuer, auth.audiences)
+	if err != nil {
+		log.Error("invalid refresh token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	stored, err := auth.usrProvider.RefreshToken(ctx, claims.ID)
+	if err != nil {
+		log.Error("refresh token not found", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := auth.usrSaver.RemoveRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		log.Error("failed to remove refresh token family", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if accessToken != "" {
+		if access, err := jwt.ParseAccessToken(accessToken, auth.accessKeys, auth.issuer, auth.audiences); err == nil && access.Subject == stored.UserID {
+			if err := auth.revokeAccessToken(ctx, access); err != nil {
+				log.Error("failed to revoke access token", slog.String("error", err.Error()))
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	log.Info("user logged out")
+	return nil
+}
+
+func (auth *Auth) LogoutAll(ctx context.Context, token string) error {
+	const op = "auth.LogoutAll"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.RemoveUserRefreshTokens(ctx, claims.Subject); err != nil {
+		log.Error("failed to remove refresh tokens", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.revokeAccessToken(ctx, claims); err != nil {
+		log.Error("failed to revoke access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged out from all sessions")
+	return nil
+}
+
+func (auth *Auth) JWKS(ctx context.Context) jwt.JSONWebKeySet {
+	return jwt.NewJSONWebKeySet(auth.accessKeys)
+}
+
+func (auth *Auth) GetUser(ctx context.Context, token string, audience string) (*models.UserInfo, error) {
 	const op = "auth.GetUser"
 
 	log := auth.log.With(slog.String("op", op))
 
-	claims, err := jwt.ParseAccessToken(token, auth.accessSecret)
+	audiences := auth.audiences
+	if audience != "" {
+		if !slices.Contains(auth.audiences, audience) {
+			log.Error("invalid audience", slog.String("audience", audience))
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAudience)
+		}
+		audiences = []string{audience}
+	}
+
+	claims, err := auth.verifyAccessToken(ctx, token, audiences)
 	if err != nil {
-		log.Error("failed to parse access token", err)
-		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	user, err := auth.usrProvider.User(ctx, claims.UserID)
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
 	if err != nil {
-		log.Error("failed to get user by access token", err)
+		log.Error("failed to get user by access token", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
@@ -167,19 +568,119 @@ func (auth *Auth) GetUser(ctx context.Context, token string) (*models.UserInfo,
 	}, nil
 }
 
-func (auth *Auth) createTokens(ctx context.Context, user *models.UserModel) (*models.UserResponse, error) {
-	token, err := jwt.NewAccessToken(*user, auth.accessSecret, auth.accessTTL)
+// audience resolves the audience requested by a client, defaulting to the
+// first configured one.
+func (auth *Auth) audience(requested string) (string, error) {
+	if requested == "" {
+		return auth.audiences[0], nil
+	}
+	if !slices.Contains(auth.audiences, requested) {
+		return "", ErrInvalidAudience
+	}
+	return requested, nil
+}
+
+// verifyAccessToken parses the token and rejects it when its jti is on the
+// revocation list or it was issued before the user's token version changed.
+func (auth *Auth) verifyAccessToken(ctx context.Context, token string, audiences []string) (*jwt.AccessClaims, error) {
+	claims, err := jwt.ParseAccessToken(token, auth.accessKeys, auth.issuer, audiences)
 	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := auth.revoker.AccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	version, err := auth.usrProvider.TokenVersion(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
 		return nil, err
 	}
+	if claims.Version != version {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before sessions were tracked carry no session id.
+	if claims.SessionID != "" {
+		active, err := auth.usrProvider.SessionActive(ctx, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	return claims, nil
+}
 
+func (auth *Auth) revokeAccessToken(ctx context.Context, claims *jwt.AccessClaims) error {
+	return auth.revoker.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// revokeReusedFamily handles presentation of an already rotated refresh token:
+// the token was most likely stolen, so every descendant session is revoked.
+func (auth *Auth) revokeReusedFamily(ctx context.Context, log *slog.Logger, stored *models.RefreshToken) {
+	log.Warn("refresh token reuse detected",
+		slog.String("user_id", stored.UserID),
+		slog.String("family_id", stored.FamilyID),
+		slog.String("token_id", stored.TokenID),
+	)
+
+	if err := auth.usrSaver.RemoveRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		log.Error("failed to revoke refresh token family", slog.String("error", err.Error()))
+	}
+
+	event := models.AuditEvent{
+		UserID: stored.UserID,
+		Event:  models.EventRefreshTokenReuse,
+		Metadata: map[string]string{
+			"family_id": stored.FamilyID,
+			"token_id":  stored.TokenID,
+		},
+	}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save security event", slog.String("error", err.Error()))
+	}
+}
+
+// createTokens issues an access/refresh pair. A nil parent starts a new token
+// family, otherwise the refresh token continues the parent's family.
+func (auth *Auth) createTokens(ctx context.Context, user *models.UserModel, audience string, parent *models.RefreshToken) (*models.UserResponse, error) {
 	tokenID := uuid.New().String()
-	refresh, err := jwt.NewRefreshToken(user.ID, tokenID, auth.refreshSecret, auth.refreshTTL)
+	client := clientinfo.FromContext(ctx)
+	stored := models.RefreshToken{
+		TokenID:    tokenID,
+		UserID:     user.ID,
+		FamilyID:   tokenID,
+		UserAgent:  client.UserAgent,
+		ClientIP:   client.IP,
+		DeviceName: client.DeviceName,
+		ExpiresAt:  time.Now().Add(auth.refreshTTL),
+	}
+	if parent != nil {
+		stored.FamilyID = parent.FamilyID
+		stored.ParentTokenID = parent.TokenID
+	}
+
+	token, err := jwt.NewAccessToken(*user, stored.FamilyID, auth.accessKeys, auth.issuer, audience, auth.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := jwt.NewRefreshToken(user.ID, tokenID, auth.refreshKeys, auth.issuer, audience, auth.refreshTTL)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := auth.usrSaver.SaveRefreshToken(ctx, tokenID, user.ID, time.Now().Add(auth.refreshTTL)); err != nil {
+	if err := auth.usrSaver.SaveRefreshToken(ctx, stored); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	rotated, err := auth.Refresh(ctx, user.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	latest, err := auth.Refresh(ctx, rotated.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh of the rotated token: %v", err)
+	}
+	if store.eventCount(models.EventRefreshTokenReuse) != 0 {
+		t.Fatal("reuse reported for a normal rotation")
+	}
+
+	// Presenting a rotated token again revokes the whole family.
+	if _, err := auth.Refresh(ctx, user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh of a reused token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, latest.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh of a descendant of a reused token: err = %v, want ErrInvalidToken", err)
+	}
+	if got := store.eventCount(models.EventRefreshTokenReuse); got != 1 {
+		t.Errorf("reuse events = %d, want 1", got)
+	}
+}
+
+func TestRefreshConcurrentReuse(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	// A second request with the same token rotates it between the read and
+	// the rotation of the first one.
+	var winner *models.Refresh
+	store.beforeRotate = func() {
+		var err error
+		if winner, err = auth.Refresh(ctx, user.RefreshToken); err != nil {
+			t.Errorf("concurrent Refresh: %v", err)
+		}
+	}
+	if _, err := auth.Refresh(ctx, user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Refresh losing the race: err = %v, want ErrInvalidToken", err)
+	}
+	if winner == nil {
+		t.Fatal("concurrent Refresh did not run")
+	}
+	if _, err := auth.Refresh(ctx, winner.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh of the winning token: err = %v, want ErrInvalidToken", err)
+	}
+	if got := store.eventCount(models.EventRefreshTokenReuse); got != 1 {
+		t.Errorf("reuse events = %d, want 1", got)
+	}
+}
+
+func TestRefreshReuseKeepsOtherFamilies(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	first := register(t, auth, "user@example.com", "secret")
+	second, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.Refresh(ctx, first.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Refresh of a reused token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, second.RefreshToken); err != nil {
+		t.Errorf("Refresh of another session: %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	rotated, err := auth.Refresh(ctx, user.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging out with an old token of the session ends it too.
+	if err := auth.Logout(ctx, user.RefreshToken, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh after Logout: err = %v, want ErrInvalidToken", err)
+	}
+	if err := auth.Logout(ctx, "not a token", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Logout with garbage: err = %v, want ErrInvalidToken", err)
+	}
+	if err := auth.Logout(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Logout with an access token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	first := register(t, auth, "user@example.com", "secret")
+	second, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := register(t, auth, "other@example.com", "secret")
+
+	if err := auth.LogoutAll(ctx, second.Token); err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+	for _, session := range []string{first.RefreshToken, second.RefreshToken} {
+		if _, err := auth.Refresh(ctx, session); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Refresh after LogoutAll: err = %v, want ErrInvalidToken", err)
+		}
+	}
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh of another user: %v", err)
+	}
+
+	if err := auth.LogoutAll(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("LogoutAll with a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAudience(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+
+	if _, err := auth.Register(ctx, "Test User", "user@example.com", "secret", "other"); !errors.Is(err, ErrInvalidAudience) {
+		t.Fatalf("Register for an unknown audience: err = %v, want ErrInvalidAudience", err)
+	}
+	admin, err := auth.Register(ctx, "Test User", "user@example.com", "secret", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		audience string
+		want     error
+	}{
+		{"any configured audience", admin.Token, "", nil},
+		{"requested audience", admin.Token, "admin", nil},
+		{"default audience", app.Token, "app", nil},
+		{"other audience", admin.Token, "app", ErrInvalidToken},
+		{"unknown audience", app.Token, "other", ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		if _, err := auth.GetUser(ctx, tt.token, tt.audience); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Refreshed tokens keep the audience of the session.
+	refreshed, err := auth.Refresh(ctx, admin.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.GetUser(ctx, refreshed.Token, "admin"); err != nil {
+		t.Errorf("GetUser with a refreshed token: %v", err)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	second, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := register(t, auth, "other@example.com", "secret")
+
+	// Another user's access token is left alone.
+	if err := auth.Logout(ctx, user.RefreshToken, other.Token); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, other.Token, ""); err != nil {
+		t.Errorf("GetUser of another user: %v", err)
+	}
+
+	if err := auth.Logout(ctx, second.RefreshToken, second.Token); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, second.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser after Logout: err = %v, want ErrInvalidToken", err)
+	}
+	if result, err := auth.Introspect(ctx, testClientID, testClientSecret, second.Token, ""); err != nil || result.Active {
+		t.Errorf("Introspect after Logout: result = %+v, err = %v", result, err)
+	}
+}
+
+func TestLogoutAllRevokesAccessToken(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if err := auth.LogoutAll(ctx, user.Token); err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser after LogoutAll: err = %v, want ErrInvalidToken", err)
+	}
+	if err := auth.LogoutAll(ctx, user.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("LogoutAll with a revoked token: err = %v, want ErrInvalidToken", err)
+	}
+}
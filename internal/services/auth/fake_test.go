@@ -0,0 +1,839 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/passhash"
+	"auth-api/internal/lib/secretbox"
+	"auth-api/internal/lib/throttle"
+	"auth-api/internal/lib/webauthn"
+	"auth-api/internal/storage"
+	"auth-api/internal/storage/memory"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeStorage keeps users, tokens, audit events and sent mail in memory.
+// Methods a test does not need are left to the embedded nil interfaces and
+// panic when called.
+type fakeStorage struct {
+	UserSaver
+	UserProvider
+
+	mu            sync.Mutex
+	users         map[string]*models.UserModel
+	deleted       map[string]time.Time
+	refresh       map[string]*models.RefreshToken
+	verifications map[string]models.EmailVerification
+	resets        map[string]models.PasswordReset
+	emailChanges  map[string]models.EmailChange
+	totp          map[string]models.TOTP
+	recovery      map[string]models.RecoveryCode
+	challenges    map[string]models.WebAuthnChallenge
+	passkeys      map[string]models.Passkey
+	events        []models.AuditEvent
+	sent          []mailer.Message
+	sendErr       error
+	// beforeRotate, when set, runs at the start of RotateRefreshToken.
+	beforeRotate func()
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		users:         make(map[string]*models.UserModel),
+		deleted:       make(map[string]time.Time),
+		refresh:       make(map[string]*models.RefreshToken),
+		verifications: make(map[string]models.EmailVerification),
+		resets:        make(map[string]models.PasswordReset),
+		emailChanges:  make(map[string]models.EmailChange),
+		totp:          make(map[string]models.TOTP),
+		recovery:      make(map[string]models.RecoveryCode),
+		challenges:    make(map[string]models.WebAuthnChallenge),
+		passkeys:      make(map[string]models.Passkey),
+	}
+}
+
+func (s *fakeStorage) CreateUser(ctx context.Context, name string, email string, passHash []byte, pepper int) (*models.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if _, deleted := s.deleted[user.ID]; user.Email == email && !deleted {
+			return nil, storage.ErrUserExists
+		}
+	}
+	user := &models.UserModel{
+		ID:            uuid.New().String(),
+		Email:         email,
+		Name:          name,
+		PasswordHash:  passHash,
+		PepperVersion: pepper,
+		CreatedAt:     time.Now(),
+	}
+	s.users[user.ID] = user
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeStorage) User(ctx context.Context, email string) (*models.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if _, deleted := s.deleted[user.ID]; user.Email == email && !deleted {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) UserByID(ctx context.Context, userID string) (*models.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return nil, storage.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeStorage) TokenVersion(ctx context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return 0, storage.ErrUserNotFound
+	}
+	return user.TokenVersion, nil
+}
+
+func (s *fakeStorage) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.CreatedAt = time.Now()
+	s.refresh[token.TokenID] = &token
+	return nil
+}
+
+func (s *fakeStorage) RefreshToken(ctx context.Context, tokenID string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refresh[tokenID]
+	if !ok {
+		return nil, storage.ErrTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (s *fakeStorage) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refresh {
+		if token.FamilyID == sessionID && token.RotatedAt == nil && token.ExpiresAt.After(time.Now()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeStorage) RotateRefreshToken(ctx context.Context, tokenID string) error {
+	if hook := s.beforeRotate; hook != nil {
+		s.beforeRotate = nil
+		hook()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refresh[tokenID]
+	if !ok || token.RotatedAt != nil {
+		return storage.ErrTokenNotFound
+	}
+	now := time.Now()
+	token.RotatedAt = &now
+	return nil
+}
+
+func (s *fakeStorage) RemoveRefreshTokenFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tokenID, token := range s.refresh {
+		if token.FamilyID == familyID {
+			delete(s.refresh, tokenID)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStorage) RemoveUserRefreshTokens(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tokenID, token := range s.refresh {
+		if token.UserID == userID {
+			delete(s.refresh, tokenID)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStorage) RemoveUserSession(ctx context.Context, userID string, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+	for tokenID, token := range s.refresh {
+		if token.UserID == userID && token.FamilyID == sessionID {
+			delete(s.refresh, tokenID)
+			found = true
+		}
+	}
+	if !found {
+		return storage.ErrSessionNotFound
+	}
+	return nil
+}
+
+// UserSessions describes each token family by its latest token.
+func (s *fakeStorage) UserSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []models.Session
+	for _, token := range s.refresh {
+		if token.UserID != userID || token.RotatedAt != nil {
+			continue
+		}
+		createdAt := token.CreatedAt
+		if first, ok := s.refresh[token.FamilyID]; ok {
+			createdAt = first.CreatedAt
+		}
+		sessions = append(sessions, models.Session{
+			ID:         token.FamilyID,
+			UserAgent:  token.UserAgent,
+			ClientIP:   token.ClientIP,
+			DeviceName: token.DeviceName,
+			CreatedAt:  createdAt,
+			LastUsedAt: token.CreatedAt,
+			ExpiresAt:  token.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
+
+func (s *fakeStorage) SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, pending := range s.verifications {
+		if pending.UserID == verification.UserID {
+			delete(s.verifications, hash)
+		}
+	}
+	s.verifications[string(verification.TokenHash)] = verification
+	return nil
+}
+
+func (s *fakeStorage) ConsumeEmailVerification(ctx context.Context, tokenHash []byte) (*models.EmailVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.verifications[string(tokenHash)]
+	delete(s.verifications, string(tokenHash))
+	if !ok || !verification.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &verification, nil
+}
+
+func (s *fakeStorage) MarkEmailVerified(ctx context.Context, userID string, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted || user.Email != email {
+		return storage.ErrUserNotFound
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (s *fakeStorage) SavePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, pending := range s.resets {
+		if pending.UserID == reset.UserID {
+			delete(s.resets, hash)
+		}
+	}
+	s.resets[string(reset.TokenHash)] = reset
+	return nil
+}
+
+func (s *fakeStorage) PasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[string(tokenHash)]
+	if !ok || !reset.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &reset, nil
+}
+
+func (s *fakeStorage) ConsumePasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[string(tokenHash)]
+	delete(s.resets, string(tokenHash))
+	if !ok || !reset.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &reset, nil
+}
+
+func (s *fakeStorage) UpdatePassword(ctx context.Context, userID string, passHash []byte, pepper int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return storage.ErrUserNotFound
+	}
+	user.PasswordHash = passHash
+	user.PepperVersion = pepper
+	user.TokenVersion++
+	return nil
+}
+
+func (s *fakeStorage) UpgradePasswordHash(ctx context.Context, userID string, oldHash []byte, newHash []byte, pepper int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok && bytes.Equal(user.PasswordHash, oldHash) {
+		user.PasswordHash = newHash
+		user.PepperVersion = pepper
+	}
+	return nil
+}
+
+func (s *fakeStorage) UpdateUserName(ctx context.Context, userID string, name string) (*models.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return nil, storage.ErrUserNotFound
+	}
+	user.Name = name
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeStorage) SaveEmailChange(ctx context.Context, change models.EmailChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, pending := range s.emailChanges {
+		if pending.UserID == change.UserID {
+			delete(s.emailChanges, hash)
+		}
+	}
+	s.emailChanges[string(change.TokenHash)] = change
+	return nil
+}
+
+func (s *fakeStorage) ConsumeEmailChange(ctx context.Context, tokenHash []byte) (*models.EmailChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, ok := s.emailChanges[string(tokenHash)]
+	delete(s.emailChanges, string(tokenHash))
+	if !ok || !change.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &change, nil
+}
+
+func (s *fakeStorage) UpdateUserEmail(ctx context.Context, userID string, email string) (*models.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.users {
+		if _, deleted := s.deleted[other.ID]; other.ID != userID && other.Email == email && !deleted {
+			return nil, storage.ErrUserExists
+		}
+	}
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return nil, storage.ErrUserNotFound
+	}
+	now := time.Now()
+	user.Email = email
+	user.EmailVerifiedAt = &now
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeStorage) SoftDeleteUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return storage.ErrUserNotFound
+	}
+	s.deleted[userID] = time.Now()
+	user.TokenVersion++
+	for tokenID, token := range s.refresh {
+		if token.UserID == userID {
+			delete(s.refresh, tokenID)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStorage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for userID, deletedAt := range s.deleted {
+		if deletedAt.Before(deletedBefore) {
+			delete(s.users, userID)
+			delete(s.deleted, userID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *fakeStorage) UserAuditEvents(ctx context.Context, userID string) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, event := range s.events {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeStorage) SaveTOTP(ctx context.Context, totp models.TOTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totp[totp.UserID] = totp
+	return nil
+}
+
+func (s *fakeStorage) UserTOTP(ctx context.Context, userID string) (*models.TOTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totp[userID]
+	if !ok {
+		return nil, storage.ErrMFANotFound
+	}
+	return &totp, nil
+}
+
+func (s *fakeStorage) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totp[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return storage.ErrMFACodeUsed
+	}
+	if totp.ConfirmedAt == nil {
+		now := time.Now()
+		totp.ConfirmedAt = &now
+	}
+	totp.LastUsedStep = step
+	s.totp[userID] = totp
+	return nil
+}
+
+func (s *fakeStorage) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []models.RecoveryCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for codeID, code := range s.recovery {
+		if code.UserID == userID {
+			delete(s.recovery, codeID)
+		}
+	}
+	for _, code := range codes {
+		s.recovery[code.ID] = code
+	}
+	return nil
+}
+
+func (s *fakeStorage) UseRecoveryCode(ctx context.Context, codeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recovery[codeID]; !ok {
+		return storage.ErrMFACodeUsed
+	}
+	delete(s.recovery, codeID)
+	return nil
+}
+
+func (s *fakeStorage) UnusedRecoveryCodes(ctx context.Context, userID string) ([]models.RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var codes []models.RecoveryCode
+	for _, code := range s.recovery {
+		if code.UserID == userID {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+func (s *fakeStorage) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	codes, err := s.UnusedRecoveryCodes(ctx, userID)
+	return len(codes), err
+}
+
+func (s *fakeStorage) SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[string(challenge.Challenge)] = challenge
+	return nil
+}
+
+func (s *fakeStorage) ConsumeWebAuthnChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.challenges[string(challenge)]
+	if !ok || stored.Ceremony != ceremony || time.Now().After(stored.ExpiresAt) {
+		return nil, storage.ErrTokenNotFound
+	}
+	delete(s.challenges, string(challenge))
+	return &stored, nil
+}
+
+func (s *fakeStorage) PurgeWebAuthnChallenges(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key, challenge := range s.challenges {
+		if challenge.ExpiresAt.Before(expiredBefore) {
+			delete(s.challenges, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *fakeStorage) SavePasskey(ctx context.Context, passkey models.Passkey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.passkeys[string(passkey.ID)]; ok {
+		return storage.ErrPasskeyExists
+	}
+	passkey.CreatedAt = time.Now()
+	s.passkeys[string(passkey.ID)] = passkey
+	return nil
+}
+
+func (s *fakeStorage) UpdatePasskeySignCount(ctx context.Context, credentialID []byte, signCount uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passkey, ok := s.passkeys[string(credentialID)]
+	if !ok {
+		return storage.ErrPasskeyNotFound
+	}
+	now := time.Now()
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = &now
+	s.passkeys[string(credentialID)] = passkey
+	return nil
+}
+
+func (s *fakeStorage) Passkey(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passkey, ok := s.passkeys[string(credentialID)]
+	if !ok {
+		return nil, storage.ErrPasskeyNotFound
+	}
+	return &passkey, nil
+}
+
+func (s *fakeStorage) UserPasskeys(ctx context.Context, userID string) ([]models.Passkey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var passkeys []models.Passkey
+	for _, passkey := range s.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (s *fakeStorage) RecordFailedLogin(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	user.FailedAttempts++
+	if user.FailedAttempts < maxAttempts {
+		return nil, nil
+	}
+	until := time.Now().Add(lockFor)
+	user.FailedAttempts = 0
+	user.LockedUntil = &until
+	return &until, nil
+}
+
+func (s *fakeStorage) ResetFailedLogins(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok {
+		user.FailedAttempts = 0
+	}
+	return nil
+}
+
+func (s *fakeStorage) LockUser(ctx context.Context, userID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return storage.ErrUserNotFound
+	}
+	user.LockedUntil = &until
+	user.TokenVersion++
+	for tokenID, token := range s.refresh {
+		if token.UserID == userID {
+			delete(s.refresh, tokenID)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStorage) UnlockUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if _, deleted := s.deleted[userID]; !ok || deleted {
+		return storage.ErrUserNotFound
+	}
+	user.LockedUntil = nil
+	user.FailedAttempts = 0
+	return nil
+}
+
+// lastMail returns the last message sent to the address.
+func (s *fakeStorage) lastMail(t *testing.T, to string) mailer.Message {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.sent) - 1; i >= 0; i-- {
+		if s.sent[i].To == to {
+			return s.sent[i]
+		}
+	}
+	t.Fatalf("no mail sent to %s", to)
+	return mailer.Message{}
+}
+
+func (s *fakeStorage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.CreatedAt = time.Now()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// eventCount returns how many audit events of the kind were saved.
+func (s *fakeStorage) eventCount(kind string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, event := range s.events {
+		if event.Event == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *fakeStorage) Send(ctx context.Context, msg mailer.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// mailedToken returns the token of the last link mailed to the address.
+func (s *fakeStorage) mailedToken(t *testing.T, to string) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.sent) - 1; i >= 0; i-- {
+		if s.sent[i].To != to {
+			continue
+		}
+		_, rest, ok := strings.Cut(s.sent[i].Body, "token=")
+		if !ok {
+			t.Fatalf("mail to %s has no link: %q", to, s.sent[i].Body)
+		}
+		return strings.Fields(rest)[0]
+	}
+	t.Fatalf("no mail sent to %s", to)
+	return ""
+}
+
+func (s *fakeStorage) mailCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sent)
+}
+
+// newTestAuth builds the service over fake storage. Options adjust the
+// configuration before the service is created.
+func newTestAuth(t *testing.T, options ...func(*Config)) (*Auth, *fakeStorage) {
+	t.Helper()
+	store := newFakeStorage()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := Config{
+		AccessTTL:       time.Minute,
+		AccessKeys:      testKeyRing(t, "access-secret"),
+		RefreshTTL:      time.Hour,
+		RefreshKeys:     testKeyRing(t, "refresh-secret"),
+		Issuer:          "auth-api",
+		Audiences:       []string{"app", "admin"},
+		VerificationTTL: time.Hour,
+		VerificationURL: "https://example.com/verify?token=%s",
+		EmailChangeURL:  "https://example.com/email?token=%s",
+
+		PasswordResetTTL: time.Hour,
+		PasswordResetURL: "https://example.com/reset?token=%s",
+
+		DeletionRetention: 30 * 24 * time.Hour,
+
+		SecretBox:       testSecretBox(t),
+		TOTPIssuer:      "Deimos",
+		MFAChallengeTTL: 5 * time.Minute,
+
+		WebAuthn: webauthn.New(webauthn.Config{
+			RPID:    testRPID,
+			RPName:  "Example",
+			Origins: []string{testOrigin},
+		}),
+		PasskeyChallengeTTL: 5 * time.Minute,
+
+		PasswordHasher: testHasher(t, passhash.AlgorithmArgon2id),
+
+		IntrospectionClients: map[string]string{testClientID: testClientSecret},
+	}
+	cfg.AccountThrottle, cfg.IPThrottle = testThrottles(3, 10)
+	for _, option := range options {
+		option(&cfg)
+	}
+	return New(log, store, store, memory.NewRevocations(nil, time.Minute), store, cfg), store
+}
+
+func testKeyRing(t *testing.T, secret string) *jwt.KeyRing {
+	t.Helper()
+	key, err := jwt.NewHMACKey("k1", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := jwt.NewKeyRing("k1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+// testThrottles block an account after accountAttempts failures and a
+// client address after ipAttempts failures, for a minute at first.
+func testThrottles(accountAttempts int, ipAttempts int) (*throttle.Throttler, *throttle.Throttler) {
+	attempts := memory.NewLoginAttempts()
+	policy := func(free int) throttle.Policy {
+		return throttle.Policy{FreeAttempts: free, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	}
+	return throttle.New("account", attempts, policy(accountAttempts)), throttle.New("ip", attempts, policy(ipAttempts))
+}
+
+func testSecretBox(t *testing.T) *secretbox.Box {
+	t.Helper()
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+// testHasher uses the cheapest costs either algorithm accepts.
+func testHasher(t *testing.T, algorithm string) *passhash.Hasher {
+	t.Helper()
+	params := passhash.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hasher, err := passhash.New(algorithm, params, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func register(t *testing.T, auth *Auth, email string, password string) *models.UserResponse {
+	t.Helper()
+	user, err := auth.Register(context.Background(), "Test User", email, password, "")
+	if err != nil {
+		t.Fatalf("Register(%s): %v", email, err)
+	}
+	return user
+}
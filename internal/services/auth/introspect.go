@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/storage"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Introspect reports whether a token is currently active following RFC 7662:
+// invalid, expired or revoked tokens are reported as inactive, not as errors.
+// The hint only decides which token type is tried first. Only the clients
+// configured in IntrospectionClients may ask.
+func (auth *Auth) Introspect(ctx context.Context, clientID string, clientSecret string, token string, tokenTypeHint string) (*models.Introspection, error) {
+	const op = "auth.Introspect"
+	log := auth.log.With(slog.String("op", op))
+
+	if !auth.isIntrospectionClient(clientID, clientSecret) {
+		log.Warn("introspection client rejected", slog.String("client_id", clientID))
+		return nil, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	inspectors := []func(context.Context, string) (*models.Introspection, error){
+		auth.introspectAccess,
+		auth.introspectRefresh,
+	}
+	if tokenTypeHint == models.TokenTypeRefresh {
+		inspectors[0], inspectors[1] = inspectors[1], inspectors[0]
+	}
+
+	for _, inspect := range inspectors {
+		result, err := inspect(ctx, token)
+		if err != nil {
+			log.Error("failed to introspect token", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if result.Active {
+			return result, nil
+		}
+	}
+
+	return &models.Introspection{Active: false}, nil
+}
+
+func (auth *Auth) introspectAccess(ctx context.Context, token string) (*models.Introspection, error) {
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return &models.Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	return &models.Introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		ClientID:  claims.Audience[0],
+		TokenType: models.TokenTypeAccess,
+		ExpiresAt: claims.ExpiresAt.Time,
+		IssuedAt:  claims.IssuedAt.Time,
+	}, nil
+}
+
+func (auth *Auth) introspectRefresh(ctx context.Context, token string) (*models.Introspection, error) {
+	claims, err := jwt.ParseRefreshToken(token, auth.refreshKeys, auth.issuer, auth.audiences)
+	if err != nil {
+		return &models.Introspection{Active: false}, nil
+	}
+
+	stored, err := auth.usrProvider.RefreshToken(ctx, claims.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return &models.Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+	if stored.RotatedAt != nil {
+		return &models.Introspection{Active: false}, nil
+	}
+
+	// Refresh tokens of deleted and locked accounts are not active.
+	user, err := auth.usrProvider.UserByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return &models.Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+	if user.Locked(time.Now()) {
+		return &models.Introspection{Active: false}, nil
+	}
+
+	return &models.Introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		ClientID:  claims.Audience[0],
+		TokenType: models.TokenTypeRefresh,
+		ExpiresAt: claims.ExpiresAt.Time,
+		IssuedAt:  claims.IssuedAt.Time,
+	}, nil
+}
+
+func (auth *Auth) isIntrospectionClient(clientID string, clientSecret string) bool {
+	secret, ok := auth.introspectionClients[clientID]
+	return ok && secret != "" && subtle.ConstantTimeCompare([]byte(clientSecret), []byte(secret)) == 1
+}
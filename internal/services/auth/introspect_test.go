@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// The resource server newTestAuth allows to introspect tokens.
+const (
+	testClientID     = "gateway"
+	testClientSecret = "gateway-secret"
+)
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	other, err := auth.Login(ctx, "user@example.com", "secret", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Logout(ctx, other.RefreshToken, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		hint      string
+		active    bool
+		tokenType string
+		clientID  string
+	}{
+		{"access token", user.Token, "", true, models.TokenTypeAccess, "app"},
+		{"refresh token", user.RefreshToken, "", true, models.TokenTypeRefresh, "app"},
+		{"refresh token with hint", user.RefreshToken, models.TokenTypeRefresh, true, models.TokenTypeRefresh, "app"},
+		{"wrong hint", user.Token, models.TokenTypeRefresh, true, models.TokenTypeAccess, "app"},
+		{"access token of an ended session", other.Token, "", false, "", ""},
+		{"refresh token of an ended session", other.RefreshToken, "", false, "", ""},
+		{"garbage", "not a token", "", false, "", ""},
+	}
+	for _, tt := range tests {
+		result, err := auth.Introspect(ctx, testClientID, testClientSecret, tt.token, tt.hint)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if result.Active != tt.active || result.TokenType != tt.tokenType || result.ClientID != tt.clientID {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+		if result.Active && (result.Subject != user.ID || result.ExpiresAt.Before(result.IssuedAt)) {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+	}
+
+	// A rotated refresh token is no longer active, its session still is.
+	refreshed, err := auth.Refresh(ctx, user.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := auth.Introspect(ctx, testClientID, testClientSecret, user.RefreshToken, ""); err != nil || result.Active {
+		t.Errorf("rotated refresh token: result = %+v, err = %v", result, err)
+	}
+	for _, token := range []string{user.Token, refreshed.Token, refreshed.RefreshToken} {
+		if result, err := auth.Introspect(ctx, testClientID, testClientSecret, token, ""); err != nil || !result.Active {
+			t.Errorf("token of the rotated session: result = %+v, err = %v", result, err)
+		}
+	}
+}
+
+func TestIntrospectClient(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+	}{
+		{"no credentials", "", ""},
+		{"wrong secret", testClientID, "guess"},
+		{"unknown client", "app", testClientSecret},
+	}
+	for _, tt := range tests {
+		if _, err := auth.Introspect(ctx, tt.clientID, tt.secret, user.Token, ""); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: err = %v, want ErrPermissionDenied", tt.name, err)
+		}
+	}
+
+	noSecret, _ := newTestAuth(t, func(cfg *Config) { cfg.IntrospectionClients = map[string]string{testClientID: ""} })
+	if _, err := noSecret.Introspect(ctx, testClientID, "", user.Token, ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("client without a secret: err = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestIntrospectRefreshOfInactiveUser(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, withLockout)
+	locked := register(t, auth, "locked@example.com", "secret")
+	deleted := register(t, auth, "deleted@example.com", "secret")
+
+	for range 3 {
+		auth.Login(ctx, "locked@example.com", "wrong", "")
+	}
+	store.mu.Lock()
+	store.deleted[deleted.ID] = time.Now()
+	store.mu.Unlock()
+
+	for _, token := range []string{locked.RefreshToken, deleted.RefreshToken} {
+		if result, err := auth.Introspect(ctx, testClientID, testClientSecret, token, models.TokenTypeRefresh); err != nil || result.Active {
+			t.Errorf("refresh token of an inactive user: result = %+v, err = %v", result, err)
+		}
+	}
+}
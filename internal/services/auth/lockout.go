@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// lockForever is stored for locks without an end.
+var lockForever = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// AccountLockedError is returned for a correct password on a locked account.
+// Wrong passwords keep getting ErrInvalidCredentials, so the lock does not
+// reveal that the account exists.
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account is locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// Permanent reports a lock without an end.
+func (e *AccountLockedError) Permanent() bool {
+	return !e.Until.Before(lockForever)
+}
+
+func (e *AccountLockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// LockUser locks an account by admin action and ends its sessions. A zero
+// until locks it with no end, any other until has to be in the future.
+func (auth *Auth) LockUser(ctx context.Context, adminKey string, userID string, until time.Time) error {
+	const op = "auth.LockUser"
+	log := auth.log.With(slog.String("op", op))
+
+	if !auth.isAdmin(adminKey) {
+		log.Warn("admin key rejected")
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	if until.IsZero() {
+		until = lockForever
+	} else if !until.After(time.Now()) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidLockUntil)
+	}
+	if err := auth.usrSaver.LockUser(ctx, userID, until); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		log.Error("failed to lock user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		UserID:   userID,
+		Event:    models.EventAccountLocked,
+		Metadata: map[string]string{"by": "admin", "until": until.Format(time.RFC3339)},
+	}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	log.Info("user locked", slog.String("user_id", userID))
+	return nil
+}
+
+func (auth *Auth) UnlockUser(ctx context.Context, adminKey string, userID string) error {
+	const op = "auth.UnlockUser"
+	log := auth.log.With(slog.String("op", op))
+
+	if !auth.isAdmin(adminKey) {
+		log.Warn("admin key rejected")
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	if err := auth.usrSaver.UnlockUser(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		log.Error("failed to unlock user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{UserID: userID, Event: models.EventAccountUnlocked}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	log.Info("user unlocked", slog.String("user_id", userID))
+	return nil
+}
+
+// recordFailedLogin counts a wrong password towards the automatic lockout.
+func (auth *Auth) recordFailedLogin(ctx context.Context, log *slog.Logger, userID string) {
+	if auth.lockoutThreshold <= 0 {
+		return
+	}
+
+	lockedUntil, err := auth.usrSaver.RecordFailedLogin(ctx, userID, auth.lockoutThreshold, auth.lockoutDuration)
+	if err != nil {
+		log.Error("failed to record failed login", slog.String("error", err.Error()))
+		return
+	}
+	if lockedUntil == nil {
+		return
+	}
+
+	log.Warn("account locked after failed logins", slog.String("user_id", userID))
+	event := models.AuditEvent{
+		UserID:   userID,
+		Event:    models.EventAccountLocked,
+		Metadata: map[string]string{"by": "failed_logins", "until": lockedUntil.Format(time.RFC3339)},
+	}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+}
+
+func checkLocked(user *models.UserModel) error {
+	if user.Locked(time.Now()) {
+		return &AccountLockedError{Until: *user.LockedUntil}
+	}
+	return nil
+}
+
+func (auth *Auth) isAdmin(key string) bool {
+	return auth.adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(auth.adminKey)) == 1
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// withLockout locks accounts after three wrong passwords and keeps the
+// throttles out of the way.
+func withLockout(cfg *Config) {
+	cfg.LockoutThreshold = 3
+	cfg.LockoutDuration = 15 * time.Minute
+	cfg.AdminKey = "admin-key"
+	cfg.AccountThrottle, cfg.IPThrottle = testThrottles(100, 100)
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, withLockout)
+	user := register(t, auth, "user@example.com", "secret")
+
+	// A correct password resets the count.
+	for range 2 {
+		auth.Login(ctx, "user@example.com", "wrong", "")
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	for range 3 {
+		if _, err := auth.Login(ctx, "user@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login with a wrong password: err = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if got := store.eventCount(models.EventAccountLocked); got != 1 {
+		t.Errorf("lock events = %d, want 1", got)
+	}
+
+	_, err := auth.Login(ctx, "user@example.com", "secret", "")
+	var locked *AccountLockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login while locked: err = %v, want AccountLockedError", err)
+	}
+	if locked.Permanent() || time.Until(locked.Until) < 14*time.Minute {
+		t.Errorf("locked until %v, want about 15 minutes", locked.Until)
+	}
+	// Wrong passwords do not reveal the lock.
+	if _, err := auth.Login(ctx, "user@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with a wrong password while locked: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	past := time.Now().Add(-time.Second)
+	store.users[user.ID].LockedUntil = &past
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login after the lock expired: %v", err)
+	}
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t, func(cfg *Config) {
+		cfg.AccountThrottle, cfg.IPThrottle = testThrottles(100, 100)
+	})
+	register(t, auth, "user@example.com", "secret")
+
+	for range 10 {
+		auth.Login(ctx, "user@example.com", "wrong", "")
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login: %v", err)
+	}
+}
+
+func TestLockUser(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, withLockout)
+	user := register(t, auth, "user@example.com", "secret")
+
+	for _, key := range []string{"", "wrong"} {
+		if err := auth.LockUser(ctx, key, user.ID, time.Time{}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("LockUser with key %q: err = %v, want ErrPermissionDenied", key, err)
+		}
+		if err := auth.UnlockUser(ctx, key, user.ID); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("UnlockUser with key %q: err = %v, want ErrPermissionDenied", key, err)
+		}
+	}
+	if err := auth.LockUser(ctx, "admin-key", "7d1b5a6e-0000-4000-8000-000000000000", time.Time{}); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("LockUser of an unknown user: err = %v, want ErrUserNotFound", err)
+	}
+	for _, until := range []time.Time{time.Now(), time.Now().Add(-time.Hour)} {
+		if err := auth.LockUser(ctx, "admin-key", user.ID, until); !errors.Is(err, ErrInvalidLockUntil) {
+			t.Errorf("LockUser until %s: err = %v, want ErrInvalidLockUntil", until, err)
+		}
+	}
+
+	if err := auth.LockUser(ctx, "admin-key", user.ID, time.Time{}); err != nil {
+		t.Fatalf("LockUser: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser after LockUser: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh after LockUser: err = %v, want ErrInvalidToken", err)
+	}
+	_, err := auth.Login(ctx, "user@example.com", "secret", "")
+	var locked *AccountLockedError
+	if !errors.As(err, &locked) || !locked.Permanent() {
+		t.Fatalf("Login while locked without an end: err = %v, want a permanent lock", err)
+	}
+
+	if err := auth.UnlockUser(ctx, "admin-key", user.ID); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login after UnlockUser: %v", err)
+	}
+	if store.eventCount(models.EventAccountLocked) != 1 || store.eventCount(models.EventAccountUnlocked) != 1 {
+		t.Errorf("events = %+v", store.events)
+	}
+}
+
+func TestLockedSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t, withLockout)
+	user := register(t, auth, "user@example.com", "secret")
+	authenticator := registerPasskey(t, auth, user.Token)
+	secret, _ := enableTOTP(t, auth, user.Token)
+
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.LockUser(ctx, "admin-key", user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, totpCode(t, secret, 0)); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("VerifyMFA while locked: err = %v, want ErrAccountLocked", err)
+	}
+	if _, err := passkeyLogin(t, auth, authenticator, nil); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("FinishPasskeyLogin while locked: err = %v, want ErrAccountLocked", err)
+	}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/clientinfo"
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/totp"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const totpSkew = 1
+
+// EnrollTOTP generates a new TOTP secret for the token owner. Two-factor login
+// is enabled only after the first code is confirmed with ConfirmTOTP.
+func (auth *Auth) EnrollTOTP(ctx context.Context, token string) (*models.TOTPEnrollment, error) {
+	const op = "auth.EnrollTOTP"
+	log := auth.log.With(slog.String("op", op))
+
+	if auth.secretBox == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFADisabled)
+	}
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	enabled, err := auth.mfaEnabled(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to check mfa", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if enabled {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate totp secret", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	encrypted, err := auth.secretBox.Seal([]byte(secret))
+	if err != nil {
+		log.Error("failed to encrypt totp secret", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.SaveTOTP(ctx, models.TOTP{UserID: claims.Subject, SecretEncrypted: encrypted}); err != nil {
+		log.Error("failed to save totp secret", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(auth.totpIssuer, claims.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor login once the user proves their
+// authenticator produces valid codes, and returns the initial recovery codes.
+func (auth *Auth) ConfirmTOTP(ctx context.Context, token string, code string) ([]string, error) {
+	const op = "auth.ConfirmTOTP"
+	log := auth.log.With(slog.String("op", op))
+
+	if auth.secretBox == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFADisabled)
+	}
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := auth.usrProvider.UserTOTP(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+		}
+		log.Error("failed to get totp", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stored.ConfirmedAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	if err := auth.checkTOTP(ctx, stored, code); err != nil {
+		log.Error("invalid totp code", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := auth.issueRecoveryCodes(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to issue recovery codes", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled", slog.String("user_id", claims.Subject))
+	return codes, nil
+}
+
+// VerifyMFA completes a login started by Login with the challenge token and
+// either a TOTP code or a recovery code, and issues the regular token pair.
+// With TOTP disabled only recovery codes are accepted.
+func (auth *Auth) VerifyMFA(ctx context.Context, mfaToken string, code string) (*models.UserResponse, error) {
+	const op = "auth.VerifyMFA"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := jwt.ParseMFAToken(mfaToken, auth.refreshKeys, auth.issuer, auth.audiences)
+	if err != nil {
+		log.Error("invalid mfa token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	// A challenge answers one login: its jti is revoked once used.
+	used, err := auth.revoker.AccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		log.Error("failed to check mfa token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if used {
+		log.Warn("mfa token reused", slog.String("user_id", claims.Subject))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("user not found", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	account, ip := throttleAccount(user.Email), clientinfo.FromContext(ctx).IP
+	if err := auth.checkThrottle(ctx, account, ip); err != nil {
+		log.Warn("mfa throttled", slog.String("account", account), slog.String("ip", ip))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := auth.usrProvider.UserTOTP(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			log.Warn("totp is not enrolled", slog.String("user_id", user.ID))
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to get totp", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stored.ConfirmedAt == nil {
+		log.Warn("totp is not confirmed", slog.String("user_id", user.ID))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if isTOTPCode(code) {
+		err = auth.checkTOTP(ctx, stored, code)
+	} else {
+		err = auth.checkRecoveryCode(ctx, user.ID, code)
+	}
+	if err != nil {
+		log.Error("invalid mfa code", slog.String("error", err.Error()))
+		if errors.Is(err, ErrInvalidMFACode) {
+			auth.recordFailure(ctx, log, account, ip)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	auth.resetFailures(ctx, log, account)
+
+	if err := auth.revoker.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Error("failed to mark mfa token used", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLocked(user); err != nil {
+		log.Warn("account is locked", slog.String("user_id", user.ID))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logined")
+	return auth.createTokens(ctx, user, claims.Audience[0], nil)
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (auth *Auth) mfaEnabled(ctx context.Context, userID string) (bool, error) {
+	stored, err := auth.usrProvider.UserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return stored.ConfirmedAt != nil, nil
+}
+
+// checkTOTP validates the code against the stored secret and marks its time
+// step as used so the same code cannot be replayed.
+func (auth *Auth) checkTOTP(ctx context.Context, stored *models.TOTP, code string) error {
+	if auth.secretBox == nil {
+		return ErrMFADisabled
+	}
+	secret, err := auth.secretBox.Open(stored.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	if !ok || step <= stored.LastUsedStep {
+		return ErrInvalidMFACode
+	}
+
+	if err := auth.usrSaver.UseTOTPStep(ctx, stored.UserID, step); err != nil {
+		if errors.Is(err, storage.ErrMFACodeUsed) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"auth-api/internal/lib/jwt"
+	"auth-api/internal/lib/totp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// totpCode returns the code of the enrolled secret at the current step
+// shifted by offset steps.
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableTOTP enrolls and confirms TOTP for the token owner and returns the
+// secret and the recovery codes. The previous step is used up by the
+// confirmation.
+func enableTOTP(t *testing.T, auth *Auth, token string) (string, []string) {
+	t.Helper()
+	enrollment, err := auth.EnrollTOTP(context.Background(), token)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	codes, err := auth.ConfirmTOTP(context.Background(), token, totpCode(t, enrollment.Secret, -1))
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	return enrollment.Secret, codes
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if _, err := auth.ConfirmTOTP(ctx, user.Token, "123456"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("ConfirmTOTP before enrollment: err = %v, want ErrMFANotEnrolled", err)
+	}
+
+	enrollment, err := auth.EnrollTOTP(ctx, user.Token)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	if enrollment.Secret == "" || enrollment.URI == "" {
+		t.Fatalf("enrollment = %+v", enrollment)
+	}
+
+	// Login is unchanged until the enrollment is confirmed.
+	login, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.MFAToken != "" || login.Token == "" {
+		t.Errorf("Login before confirmation = %+v, want a token pair", login)
+	}
+
+	if _, err := auth.ConfirmTOTP(ctx, user.Token, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("ConfirmTOTP with a wrong code: err = %v, want ErrInvalidMFACode", err)
+	}
+	codes, err := auth.ConfirmTOTP(ctx, user.Token, totpCode(t, enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("recovery codes = %d, want %d", len(codes), recoveryCodeCount)
+	}
+	if _, err := auth.ConfirmTOTP(ctx, user.Token, totpCode(t, enrollment.Secret, 1)); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("ConfirmTOTP twice: err = %v, want ErrMFAAlreadyEnabled", err)
+	}
+	if _, err := auth.EnrollTOTP(ctx, user.Token); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("EnrollTOTP when enabled: err = %v, want ErrMFAAlreadyEnabled", err)
+	}
+}
+
+func TestVerifyMFAWithoutConfirmedTOTP(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	enrolled := register(t, auth, "enrolled@example.com", "secret")
+	plain := register(t, auth, "plain@example.com", "secret")
+	if _, err := auth.EnrollTOTP(ctx, enrolled.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Login never hands out challenges for these users, forge them.
+	for _, user := range []string{enrolled.ID, plain.ID} {
+		mfaToken, err := jwt.NewMFAToken(user, auth.refreshKeys, auth.issuer, "app", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.VerifyMFA(ctx, mfaToken, "123456"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyMFA for %s: err = %v, want ErrInvalidToken", user, err)
+		}
+	}
+}
+
+func TestMFADisabled(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	secret, recovery := enableTOTP(t, auth, user.Token)
+	other := register(t, auth, "other@example.com", "secret")
+
+	// The encryption key was removed from the configuration.
+	auth.secretBox = nil
+
+	if _, err := auth.EnrollTOTP(ctx, other.Token); !errors.Is(err, ErrMFADisabled) {
+		t.Errorf("EnrollTOTP: err = %v, want ErrMFADisabled", err)
+	}
+	if _, err := auth.ConfirmTOTP(ctx, other.Token, "123456"); !errors.Is(err, ErrMFADisabled) {
+		t.Errorf("ConfirmTOTP: err = %v, want ErrMFADisabled", err)
+	}
+
+	// Enrolled users still need a second factor, recovery codes keep working.
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil || challenge.MFAToken == "" {
+		t.Fatalf("Login = %+v, %v; want an mfa token", challenge, err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, totpCode(t, secret, 0)); !errors.Is(err, ErrMFADisabled) {
+		t.Errorf("VerifyMFA with a totp code: err = %v, want ErrMFADisabled", err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, recovery[0]); err != nil {
+		t.Errorf("VerifyMFA with a recovery code: %v", err)
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	secret, _ := enableTOTP(t, auth, user.Token)
+
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "admin")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if challenge.MFAToken == "" || challenge.Token != "" || challenge.RefreshToken != "" {
+		t.Fatalf("Login = %+v, want only an mfa token", challenge)
+	}
+
+	// The challenge is not an access token.
+	if _, err := auth.GetUser(ctx, challenge.MFAToken, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser with the mfa token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.VerifyMFA(ctx, user.Token, totpCode(t, secret, 0)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyMFA with an access token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with a wrong code: err = %v, want ErrInvalidMFACode", err)
+	}
+
+	code := totpCode(t, secret, 0)
+	verified, err := auth.VerifyMFA(ctx, challenge.MFAToken, code)
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, verified.Token, "admin"); err != nil {
+		t.Errorf("GetUser for the requested audience: %v", err)
+	}
+
+	// A challenge answers one login.
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, totpCode(t, secret, 1)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyMFA with a used challenge: err = %v, want ErrInvalidToken", err)
+	}
+
+	// A code works only once.
+	challenge, err = auth.Login(ctx, "user@example.com", "secret", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with a used code: err = %v, want ErrInvalidMFACode", err)
+	}
+}
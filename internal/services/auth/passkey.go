@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/clientinfo"
+	"auth-api/internal/lib/webauthn"
+	"auth-api/internal/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BeginPasskeyRegistration issues a registration challenge for the token
+// owner and returns creation options for navigator.credentials.create.
+func (auth *Auth) BeginPasskeyRegistration(ctx context.Context, token string) ([]byte, error) {
+	const op = "auth.BeginPasskeyRegistration"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	passkeys, err := auth.usrProvider.UserPasskeys(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get passkeys", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	exclude := make([][]byte, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		exclude = append(exclude, passkey.ID)
+	}
+
+	challenge, err := auth.newWebAuthnChallenge(ctx, user.ID, models.CeremonyRegistration)
+	if err != nil {
+		log.Error("failed to save challenge", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	webUser := webauthn.User{ID: userID[:], Name: user.Email, DisplayName: user.Name}
+	options, err := auth.webAuthn.CreationOptions(challenge, webUser, exclude, auth.passkeyChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return options, nil
+}
+
+// FinishPasskeyRegistration verifies the attestation returned by the browser
+// and stores the new credential.
+func (auth *Auth) FinishPasskeyRegistration(ctx context.Context, token string, clientDataJSON []byte, attestationObject []byte, name string) ([]byte, error) {
+	const op = "auth.FinishPasskeyRegistration"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := auth.consumeWebAuthnChallenge(ctx, clientDataJSON, models.CeremonyRegistration)
+	if err != nil {
+		log.Error("invalid challenge", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if challenge.UserID != claims.Subject {
+		log.Error("challenge issued to another user", slog.String("user_id", claims.Subject))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	credential, err := auth.webAuthn.VerifyRegistration(challenge.Challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		log.Error("failed to verify attestation", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	passkey := models.Passkey{
+		ID:        credential.ID,
+		UserID:    claims.Subject,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+		AAGUID:    credential.AAGUID,
+		Name:      name,
+	}
+	if err := auth.usrSaver.SavePasskey(ctx, passkey); err != nil {
+		if errors.Is(err, storage.ErrPasskeyExists) {
+			return nil, fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+		log.Error("failed to save passkey", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered", slog.String("user_id", claims.Subject))
+	return credential.ID, nil
+}
+
+// BeginPasskeyLogin issues a login challenge. No user is named: the browser
+// offers any passkey registered for the RP.
+func (auth *Auth) BeginPasskeyLogin(ctx context.Context) ([]byte, error) {
+	const op = "auth.BeginPasskeyLogin"
+	log := auth.log.With(slog.String("op", op))
+
+	challenge, err := auth.newWebAuthnChallenge(ctx, "", models.CeremonyLogin)
+	if err != nil {
+		log.Error("failed to save challenge", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := auth.webAuthn.RequestOptions(challenge, nil, auth.passkeyChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return options, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and finishes the login like a
+// password would. A user verified passkey also counts as the second factor.
+func (auth *Auth) FinishPasskeyLogin(ctx context.Context, credentialID []byte, clientDataJSON []byte, authenticatorData []byte, signature []byte, userHandle []byte, audience string) (*models.UserResponse, error) {
+	const op = "auth.FinishPasskeyLogin"
+	log := auth.log.With(slog.String("op", op))
+
+	audience, err := auth.audience(audience)
+	if err != nil {
+		log.Error("invalid audience", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ip := clientinfo.FromContext(ctx).IP
+	if err := auth.checkThrottle(ctx, "", ip); err != nil {
+		log.Warn("passkey login throttled", slog.String("ip", ip))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := auth.consumeWebAuthnChallenge(ctx, clientDataJSON, models.CeremonyLogin)
+	if err != nil {
+		log.Error("invalid challenge", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkey, err := auth.usrProvider.Passkey(ctx, credentialID)
+	if err != nil {
+		log.Error("passkey not found", slog.String("error", err.Error()))
+		auth.recordFailure(ctx, log, "", ip)
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+	if len(userHandle) != 0 {
+		userID, err := uuid.Parse(passkey.UserID)
+		if err != nil || !bytes.Equal(userHandle, userID[:]) {
+			log.Error("user handle does not match passkey owner")
+			auth.recordFailure(ctx, log, "", ip)
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+		}
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, passkey.UserID)
+	if err != nil {
+		log.Error("user not found", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+	account := throttleAccount(user.Email)
+	if err := auth.checkThrottle(ctx, account, ip); err != nil {
+		log.Warn("passkey login throttled", slog.String("account", account), slog.String("ip", ip))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored := webauthn.Credential{ID: passkey.ID, PublicKey: passkey.PublicKey, SignCount: passkey.SignCount}
+	assertion, err := auth.webAuthn.VerifyAssertion(challenge.Challenge, stored, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		log.Error("failed to verify assertion", slog.String("error", err.Error()), slog.String("user_id", passkey.UserID))
+		auth.recordFailure(ctx, log, account, ip)
+		auth.recordFailedLogin(ctx, log, user.ID)
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+	if err := auth.usrSaver.UpdatePasskeySignCount(ctx, passkey.ID, assertion.SignCount); err != nil {
+		log.Error("failed to update sign counter", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	auth.resetFailures(ctx, log, account)
+
+	resp, err := auth.finishLogin(ctx, log, user, audience, assertion.UserVerified)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return resp, nil
+}
+
+// PurgeExpiredChallenges removes passkey challenges that expired unanswered.
+func (auth *Auth) PurgeExpiredChallenges(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeExpiredChallenges"
+
+	purged, err := auth.usrSaver.PurgeWebAuthnChallenges(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return purged, nil
+}
+
+func (auth *Auth) newWebAuthnChallenge(ctx context.Context, userID string, ceremony string) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	err = auth.usrSaver.SaveWebAuthnChallenge(ctx, models.WebAuthnChallenge{
+		Challenge: challenge,
+		UserID:    userID,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(auth.passkeyChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (auth *Auth) consumeWebAuthnChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (*models.WebAuthnChallenge, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	stored, err := auth.usrSaver.ConsumeWebAuthnChallenge(ctx, challenge, ceremony)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	return stored, nil
+}
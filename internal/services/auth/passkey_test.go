@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/webauthn"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// testAuthenticator is a software passkey producing "none" attestations and
+// EdDSA assertions for the test relying party.
+type testAuthenticator struct {
+	id        []byte
+	key       ed25519.PrivateKey
+	signCount uint32
+	flags     byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{id: id, key: key, flags: flagUserPresent | flagUserVerified}
+}
+
+// create answers creation options with clientDataJSON and an attestation
+// object.
+func (a *testAuthenticator) create(t *testing.T, options []byte) ([]byte, []byte) {
+	t.Helper()
+	clientDataJSON := clientData(t, "webauthn.create", options)
+
+	// COSE OKP key: kty 1, alg -8 (EdDSA), crv 6 (Ed25519), x.
+	public := a.key.Public().(ed25519.PublicKey)
+	cose := append([]byte{0xa4, 0x01, 0x01, 0x03, 0x27, 0x20, 0x06, 0x21, 0x58, 0x20}, public...)
+
+	authData := a.authData(flagAttestedCredData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, cose...)
+
+	attestation := slices.Concat(
+		[]byte{0xa3},
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), []byte{0xa0},
+		cborText("authData"), cborBytes(authData),
+	)
+	return clientDataJSON, attestation
+}
+
+// get answers request options with clientDataJSON, authenticator data and
+// a signature.
+func (a *testAuthenticator) get(t *testing.T, options []byte) ([]byte, []byte, []byte) {
+	t.Helper()
+	clientDataJSON := clientData(t, "webauthn.get", options)
+	a.signCount++
+	authData := a.authData(0)
+	hash := sha256.Sum256(clientDataJSON)
+	return clientDataJSON, authData, ed25519.Sign(a.key, slices.Concat(authData, hash[:]))
+}
+
+func (a *testAuthenticator) authData(flags byte) []byte {
+	rpHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpHash[:], a.flags|flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func clientData(t *testing.T, typ string, options []byte) []byte {
+	t.Helper()
+	var parsed struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		t.Fatalf("options are not JSON: %v", err)
+	}
+	data, err := json.Marshal(map[string]any{"type": typ, "challenge": parsed.Challenge, "origin": testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func cborText(s string) []byte {
+	return append([]byte{0x60 | byte(len(s))}, s...)
+}
+
+func cborBytes(b []byte) []byte {
+	return append([]byte{0x58, byte(len(b))}, b...)
+}
+
+// registerPasskey registers a new software passkey for the token owner.
+func registerPasskey(t *testing.T, auth *Auth, token string) *testAuthenticator {
+	t.Helper()
+	ctx := context.Background()
+	authenticator := newTestAuthenticator(t)
+	options, err := auth.BeginPasskeyRegistration(ctx, token)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	clientDataJSON, attestation := authenticator.create(t, options)
+	credentialID, err := auth.FinishPasskeyRegistration(ctx, token, clientDataJSON, attestation, "laptop")
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	if !bytes.Equal(credentialID, authenticator.id) {
+		t.Fatalf("credential id = %x, want %x", credentialID, authenticator.id)
+	}
+	return authenticator
+}
+
+// passkeyLogin runs a login ceremony with the authenticator.
+func passkeyLogin(t *testing.T, auth *Auth, authenticator *testAuthenticator, userHandle []byte) (*models.UserResponse, error) {
+	t.Helper()
+	ctx := context.Background()
+	options, err := auth.BeginPasskeyLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	clientDataJSON, authData, signature := authenticator.get(t, options)
+	return auth.FinishPasskeyLogin(ctx, authenticator.id, clientDataJSON, authData, signature, userHandle, "")
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	other := register(t, auth, "other@example.com", "secret")
+
+	options, err := auth.BeginPasskeyRegistration(ctx, user.Token)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	var parsed struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(options, &parsed); err != nil || parsed.User.Name != "user@example.com" {
+		t.Errorf("options user = %+v, err = %v", parsed.User, err)
+	}
+
+	authenticator := newTestAuthenticator(t)
+	clientDataJSON, attestation := authenticator.create(t, options)
+
+	// The challenge belongs to the user it was issued to.
+	if _, err := auth.FinishPasskeyRegistration(ctx, other.Token, clientDataJSON, attestation, ""); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyRegistration by another user: err = %v, want ErrInvalidPasskey", err)
+	}
+	// The attempt above used the challenge up.
+	if _, err := auth.FinishPasskeyRegistration(ctx, user.Token, clientDataJSON, attestation, ""); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyRegistration with a used challenge: err = %v, want ErrInvalidPasskey", err)
+	}
+
+	registered := registerPasskey(t, auth, user.Token)
+	if passkeys, _ := store.UserPasskeys(ctx, user.ID); len(passkeys) != 1 || passkeys[0].Name != "laptop" {
+		t.Errorf("passkeys = %+v", passkeys)
+	}
+
+	// Known credentials are excluded and cannot be registered twice.
+	options, err = auth.BeginPasskeyRegistration(ctx, user.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(options, []byte(base64.RawURLEncoding.EncodeToString(registered.id))) {
+		t.Errorf("options do not exclude the registered credential: %s", options)
+	}
+	clientDataJSON, attestation = registered.create(t, options)
+	if _, err := auth.FinishPasskeyRegistration(ctx, user.Token, clientDataJSON, attestation, ""); !errors.Is(err, ErrPasskeyExists) {
+		t.Errorf("FinishPasskeyRegistration of a known credential: err = %v, want ErrPasskeyExists", err)
+	}
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	authenticator := registerPasskey(t, auth, user.Token)
+
+	login, err := passkeyLogin(t, auth, authenticator, nil)
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if login.ID != user.ID {
+		t.Errorf("logged in as %s, want %s", login.ID, user.ID)
+	}
+	if _, err := auth.GetUser(ctx, login.Token, ""); err != nil {
+		t.Errorf("GetUser with the passkey login token: %v", err)
+	}
+	if passkey, _ := store.Passkey(ctx, authenticator.id); passkey.SignCount != authenticator.signCount || passkey.LastUsedAt == nil {
+		t.Errorf("passkey = %+v, want sign count %d", passkey, authenticator.signCount)
+	}
+
+	// Each challenge answers one login.
+	options, err := auth.BeginPasskeyLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataJSON, authData, signature := authenticator.get(t, options)
+	if _, err := auth.FinishPasskeyLogin(ctx, authenticator.id, clientDataJSON, authData, signature, nil, ""); err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if _, err := auth.FinishPasskeyLogin(ctx, authenticator.id, clientDataJSON, authData, signature, nil, ""); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyLogin replayed: err = %v, want ErrInvalidPasskey", err)
+	}
+
+	impostor := newTestAuthenticator(t)
+	impostor.id = authenticator.id
+	impostor.signCount = authenticator.signCount + 10
+	if _, err := passkeyLogin(t, auth, impostor, nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyLogin signed by another key: err = %v, want ErrInvalidPasskey", err)
+	}
+	if _, err := passkeyLogin(t, auth, authenticator, []byte("someone else")); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyLogin with another user handle: err = %v, want ErrInvalidPasskey", err)
+	}
+	if _, err := passkeyLogin(t, auth, newTestAuthenticator(t), nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyLogin with an unknown credential: err = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginChecks(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t, withLockout, func(cfg *Config) {
+		cfg.AccountThrottle, cfg.IPThrottle = testThrottles(3, 100)
+	})
+	user := register(t, auth, "user@example.com", "secret")
+	authenticator := registerPasskey(t, auth, user.Token)
+
+	auth.requireVerifiedEmail = true
+	if _, err := passkeyLogin(t, auth, authenticator, nil); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("FinishPasskeyLogin with an unverified email: err = %v, want ErrEmailNotVerified", err)
+	}
+	auth.requireVerifiedEmail = false
+
+	// Assertions of another key count against the account like wrong
+	// passwords do.
+	impostor := newTestAuthenticator(t)
+	impostor.id = authenticator.id
+	impostor.signCount = authenticator.signCount + 10
+	for range 3 {
+		if _, err := passkeyLogin(t, auth, impostor, nil); !errors.Is(err, ErrInvalidPasskey) {
+			t.Fatalf("FinishPasskeyLogin signed by another key: err = %v, want ErrInvalidPasskey", err)
+		}
+	}
+	if _, err := passkeyLogin(t, auth, authenticator, nil); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("FinishPasskeyLogin after failures: err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login after passkey failures: err = %v, want ErrTooManyAttempts", err)
+	}
+
+	if err := auth.accountThrottle.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var locked *AccountLockedError
+	if _, err := passkeyLogin(t, auth, authenticator, nil); !errors.As(err, &locked) {
+		t.Errorf("FinishPasskeyLogin after the lockout threshold: err = %v, want AccountLockedError", err)
+	}
+}
+
+func TestPasskeyLoginMFA(t *testing.T) {
+	auth, _ := newTestAuth(t, func(cfg *Config) {
+		cfg.WebAuthn = webauthn.New(webauthn.Config{
+			RPID:             testRPID,
+			RPName:           "Example",
+			Origins:          []string{testOrigin},
+			UserVerification: webauthn.UserVerificationPreferred,
+		})
+	})
+	user := register(t, auth, "user@example.com", "secret")
+	authenticator := registerPasskey(t, auth, user.Token)
+	enableTOTP(t, auth, user.Token)
+
+	// A user verified passkey is both factors.
+	login, err := passkeyLogin(t, auth, authenticator, nil)
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if login.Token == "" || login.MFAToken != "" {
+		t.Errorf("verified passkey login = %+v, want tokens", login)
+	}
+
+	// Mere presence is one factor, the TOTP code is still asked for.
+	authenticator.flags = flagUserPresent
+	login, err = passkeyLogin(t, auth, authenticator, nil)
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if login.Token != "" || login.RefreshToken != "" || login.MFAToken == "" {
+		t.Errorf("unverified passkey login = %+v, want an MFA challenge", login)
+	}
+}
+
+func TestPasskeyLoginRequiresUserVerification(t *testing.T) {
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	authenticator := registerPasskey(t, auth, user.Token)
+
+	authenticator.flags = flagUserPresent
+	if _, err := passkeyLogin(t, auth, authenticator, nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyLogin without user verification: err = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPurgeExpiredChallenges(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+
+	for range 2 {
+		if _, err := auth.BeginPasskeyLogin(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var expired string
+	for key, challenge := range store.challenges {
+		challenge.ExpiresAt = time.Now().Add(-time.Minute)
+		store.challenges[key] = challenge
+		expired = key
+		break
+	}
+
+	purged, err := auth.PurgeExpiredChallenges(ctx)
+	if err != nil {
+		t.Fatalf("PurgeExpiredChallenges: %v", err)
+	}
+	if purged != 1 || len(store.challenges) != 1 {
+		t.Errorf("purged = %d, left %d; want 1 and 1", purged, len(store.challenges))
+	}
+	if _, ok := store.challenges[expired]; ok {
+		t.Error("expired challenge was kept")
+	}
+}
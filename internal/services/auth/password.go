@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/clientinfo"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/onetime"
+	"auth-api/internal/lib/passhash"
+	"auth-api/internal/lib/password"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RequestPasswordReset emails a single-use reset token. The result is the
+// same whether the email is registered or not.
+func (auth *Auth) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "auth.RequestPasswordReset"
+	log := auth.log.With(slog.String("op", op))
+
+	user, err := auth.usrProvider.User(ctx, auth.lookupEmail(email))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("password reset requested for unknown email")
+			return nil
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The token is saved and mailed in the background, so a known address
+	// answers as fast as an unknown one and failures do not tell them apart.
+	auth.background.Add(1)
+	go func() {
+		defer auth.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundTimeout)
+		defer cancel()
+		auth.sendPasswordReset(ctx, log, user)
+	}()
+	return nil
+}
+
+func (auth *Auth) sendPasswordReset(ctx context.Context, log *slog.Logger, user *models.UserModel) {
+	token, hash, err := onetime.New()
+	if err != nil {
+		log.Error("failed to generate reset token", slog.String("error", err.Error()))
+		return
+	}
+
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(auth.passwordResetTTL),
+	}
+	if err := auth.usrSaver.SavePasswordReset(ctx, reset); err != nil {
+		log.Error("failed to save reset token", slog.String("error", err.Error()))
+		return
+	}
+
+	err = auth.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Восстановление пароля",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nЕсли вы не запрашивали восстановление пароля, просто проигнорируйте это письмо.\n",
+			user.Name, fmt.Sprintf(auth.passwordResetURL, token),
+		),
+	})
+	if err != nil {
+		log.Error("failed to send reset email", slog.String("error", err.Error()))
+		return
+	}
+
+	log.Info("password reset requested", slog.String("user_id", user.ID))
+}
+
+// ResetPassword sets a new password and ends every session of the user.
+func (auth *Auth) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	const op = "auth.ResetPassword"
+	log := auth.log.With(slog.String("op", op))
+
+	pending, err := auth.usrProvider.PasswordReset(ctx, onetime.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("reset token not found", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to get reset token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, pending.UserID)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	// The policy is checked before the token is consumed, so a rejected
+	// password does not cost the user their reset link.
+	if err := auth.checkPassword(ctx, newPassword, user.Email, user.Name); err != nil {
+		log.Warn("password rejected by policy", slog.String("reason", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	reset, err := auth.usrSaver.ConsumePasswordReset(ctx, onetime.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("reset token not found", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to consume reset token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, pepper, err := auth.hasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed generate password hash", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.UpdatePassword(ctx, reset.UserID, passHash, pepper); err != nil {
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.RemoveUserRefreshTokens(ctx, reset.UserID); err != nil {
+		log.Error("failed to remove refresh tokens", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{UserID: reset.UserID, Event: models.EventPasswordReset}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	log.Info("password reset", slog.String("user_id", reset.UserID))
+	return nil
+}
+
+// ChangePassword replaces the password of the token owner. Every session is
+// ended; with keepSession the caller gets a fresh token pair instead of being
+// logged out.
+func (auth *Auth) ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string, keepSession bool) (*models.Refresh, error) {
+	const op = "auth.ChangePassword"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := auth.confirmPassword(ctx, log, user, oldPassword); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.checkPassword(ctx, newPassword, user.Email, user.Name); err != nil {
+		log.Warn("password rejected by policy", slog.String("reason", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, pepper, err := auth.hasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed generate password hash", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.UpdatePassword(ctx, user.ID, passHash, pepper); err != nil {
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.RemoveUserRefreshTokens(ctx, user.ID); err != nil {
+		log.Error("failed to remove refresh tokens", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{UserID: user.ID, Event: models.EventPasswordChanged}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	log.Info("password changed", slog.String("user_id", user.ID))
+	if !keepSession {
+		return nil, nil
+	}
+
+	user, err = auth.usrProvider.UserByID(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := auth.createTokens(ctx, user, claims.Audience[0], nil)
+	if err != nil {
+		log.Error("failed to generate tokens", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &models.Refresh{Token: data.Token, RefreshToken: data.RefreshToken}, nil
+}
+
+// checkPassword applies the password policy to a new password and looks it
+// up in known breaches. personal holds the user's email and name.
+func (auth *Auth) checkPassword(ctx context.Context, newPassword string, personal ...string) error {
+	if auth.passwordPolicy != nil {
+		if err := auth.passwordPolicy.Check(newPassword, personal...); err != nil {
+			return err
+		}
+	}
+	if auth.breachChecker == nil {
+		return nil
+	}
+
+	breached, err := auth.breachChecker.Breached(ctx, newPassword)
+	if err != nil {
+		if auth.breachFailOpen {
+			auth.log.Warn("breached password check failed, accepting password", slog.String("error", err.Error()))
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrBreachCheckFailed, err)
+	}
+	if breached {
+		return &password.Violation{Err: password.ErrBreached}
+	}
+	return nil
+}
+
+// upgradePasswordHash rehashes a verified password when its stored hash uses
+// an outdated algorithm, cost or pepper. Failures only cost the upgrade, the login
+// goes on with the old hash.
+func (auth *Auth) upgradePasswordHash(ctx context.Context, log *slog.Logger, user *models.UserModel, password string) {
+	if !auth.hasher.NeedsRehash(user.PasswordHash, user.PepperVersion) {
+		return
+	}
+
+	passHash, pepper, err := auth.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", slog.String("error", err.Error()))
+		return
+	}
+	if err := auth.usrSaver.UpgradePasswordHash(ctx, user.ID, user.PasswordHash, passHash, pepper); err != nil {
+		log.Error("failed to upgrade password hash", slog.String("error", err.Error()))
+		return
+	}
+	log.Info("password hash upgraded",
+		slog.String("user_id", user.ID),
+		slog.String("algorithm", auth.hasher.Algorithm()),
+		slog.Int("pepper", pepper),
+	)
+}
+
+// confirmPassword re-checks the password of a signed in user before a
+// sensitive change. Wrong passwords are throttled and count towards the
+// lockout exactly as on Login.
+func (auth *Auth) confirmPassword(ctx context.Context, log *slog.Logger, user *models.UserModel, current string) error {
+	account, ip := throttleAccount(user.Email), clientinfo.FromContext(ctx).IP
+	if err := auth.checkThrottle(ctx, account, ip); err != nil {
+		log.Warn("password check throttled", slog.String("account", account), slog.String("ip", ip))
+		return err
+	}
+
+	if err := auth.hasher.Verify(user.PasswordHash, user.PepperVersion, current); err != nil {
+		if !errors.Is(err, passhash.ErrMismatch) {
+			log.Error("failed to verify password", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+			return err
+		}
+		log.Error("invalid credentials", slog.String("error", err.Error()))
+		auth.recordFailure(ctx, log, account, ip)
+		auth.recordFailedLogin(ctx, log, user.ID)
+		return ErrInvalidCredentials
+	}
+	auth.resetFailures(ctx, log, account)
+
+	if err := checkLocked(user); err != nil {
+		log.Warn("account is locked", slog.String("user_id", user.ID))
+		return err
+	}
+	if user.FailedAttempts > 0 {
+		if err := auth.usrSaver.ResetFailedLogins(ctx, user.ID); err != nil {
+			log.Error("failed to reset failed logins", slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/passhash"
+	"auth-api/internal/lib/password"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if err := auth.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	auth.Wait()
+	token := store.mailedToken(t, "user@example.com")
+
+	if err := auth.ResetPassword(ctx, token, "new secret"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with the old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "new secret", ""); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh after reset: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.GetUser(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser after reset: err = %v, want ErrInvalidToken", err)
+	}
+	if got := store.eventCount(models.EventPasswordReset); got != 1 {
+		t.Errorf("reset events = %d, want 1", got)
+	}
+
+	if err := auth.ResetPassword(ctx, token, "another secret"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ResetPassword with a used token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	register(t, auth, "user@example.com", "secret")
+
+	if err := auth.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	auth.Wait()
+	first := store.mailedToken(t, "user@example.com")
+	if err := auth.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	auth.Wait()
+	if err := auth.ResetPassword(ctx, first, "new secret"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ResetPassword with a replaced token: err = %v, want ErrInvalidToken", err)
+	}
+
+	sent := store.mailCount()
+	if err := auth.RequestPasswordReset(ctx, "unknown@example.com"); err != nil {
+		t.Errorf("RequestPasswordReset for an unknown email: %v", err)
+	}
+	auth.Wait()
+	if store.mailCount() != sent {
+		t.Error("mail sent to an unknown email")
+	}
+
+	// A failing mailer is only logged, the caller sees the same answer as
+	// for an unknown address.
+	store.sendErr = errors.New("smtp is down")
+	if err := auth.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Errorf("RequestPasswordReset with a failing mailer: %v", err)
+	}
+	auth.Wait()
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	other, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.ChangePassword(ctx, user.Token, "wrong", "new secret", false); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("ChangePassword with a wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := auth.ChangePassword(ctx, user.RefreshToken, "secret", "new secret", false); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ChangePassword with a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+
+	tokens, err := auth.ChangePassword(ctx, user.Token, "secret", "new secret", false)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if tokens != nil {
+		t.Errorf("tokens = %+v, want none without keepSession", tokens)
+	}
+	if got := store.eventCount(models.EventPasswordChanged); got != 1 {
+		t.Errorf("password events = %d, want 1", got)
+	}
+
+	// Every token issued before the change stops working.
+	for _, token := range []string{user.Token, other.Token} {
+		if _, err := auth.GetUser(ctx, token, ""); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("GetUser after the change: err = %v, want ErrInvalidToken", err)
+		}
+	}
+	for _, token := range []string{user.RefreshToken, other.RefreshToken} {
+		if _, err := auth.Refresh(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Refresh after the change: err = %v, want ErrInvalidToken", err)
+		}
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "new secret", ""); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+}
+
+func TestChangePasswordKeepSession(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	tokens, err := auth.ChangePassword(ctx, user.Token, "secret", "new secret", true)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if tokens == nil {
+		t.Fatal("no tokens with keepSession")
+	}
+	if _, err := auth.GetUser(ctx, tokens.Token, ""); err != nil {
+		t.Errorf("GetUser with the new token: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, tokens.RefreshToken); err != nil {
+		t.Errorf("Refresh with the new token: %v", err)
+	}
+	if _, err := auth.GetUser(ctx, user.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser with the old token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func withPasswordPolicy(cfg *Config) {
+	cfg.PasswordPolicy = &password.Policy{MinLength: 10, MaxLength: 64, RejectPersonal: true}
+}
+
+func TestChangePasswordFailures(t *testing.T) {
+	ctx := context.Background()
+
+	// Wrong current passwords are throttled like wrong logins.
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	for range 3 {
+		if _, err := auth.ChangePassword(ctx, user.Token, "wrong", "new secret", false); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("ChangePassword with a wrong password: err = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if _, err := auth.ChangePassword(ctx, user.Token, "secret", "new secret", false); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("ChangePassword after failures: err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login after failed password changes: err = %v, want ErrTooManyAttempts", err)
+	}
+
+	// And they count towards the lockout.
+	auth, _ = newTestAuth(t, withLockout)
+	user = register(t, auth, "user@example.com", "secret")
+	for range 3 {
+		auth.ChangePassword(ctx, user.Token, "wrong", "new secret", false)
+	}
+	if _, err := auth.ChangePassword(ctx, user.Token, "secret", "new secret", false); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("ChangePassword after the lockout threshold: err = %v, want ErrAccountLocked", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login after failed password changes: err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, withPasswordPolicy)
+
+	if _, err := auth.Register(ctx, "Test User", "user@example.com", "short", ""); !errors.Is(err, password.ErrTooShort) {
+		t.Errorf("Register with a short password: err = %v, want ErrTooShort", err)
+	}
+	if _, err := auth.Register(ctx, "Test User", "user@example.com", "user-example-1", ""); !errors.Is(err, password.ErrContainsPersonal) {
+		t.Errorf("Register with the email in the password: err = %v, want ErrContainsPersonal", err)
+	}
+	user := register(t, auth, "user@example.com", "correct horse battery")
+
+	if _, err := auth.ChangePassword(ctx, user.Token, "correct horse battery", "short", false); !errors.Is(err, password.ErrTooShort) {
+		t.Errorf("ChangePassword to a short password: err = %v, want ErrTooShort", err)
+	}
+
+	// A rejected password leaves the reset link usable.
+	if err := auth.RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	auth.Wait()
+	token := store.mailedToken(t, "user@example.com")
+	var violation *password.Violation
+	if err := auth.ResetPassword(ctx, token, "short"); !errors.As(err, &violation) || violation.Limit != 10 {
+		t.Errorf("ResetPassword to a short password: err = %v, want a violation with limit 10", err)
+	}
+	if err := auth.ResetPassword(ctx, token, "staple purple lantern"); err != nil {
+		t.Errorf("ResetPassword after a rejected attempt: %v", err)
+	}
+}
+
+type fakeBreachChecker struct {
+	breached map[string]bool
+	err      error
+}
+
+func (c *fakeBreachChecker) Breached(ctx context.Context, password string) (bool, error) {
+	return c.breached[password], c.err
+}
+
+func TestBreachedPassword(t *testing.T) {
+	ctx := context.Background()
+	checker := &fakeBreachChecker{breached: map[string]bool{"leaked password": true}}
+	auth, _ := newTestAuth(t, func(cfg *Config) { cfg.BreachChecker = checker })
+
+	if _, err := auth.Register(ctx, "Test User", "user@example.com", "leaked password", ""); !errors.Is(err, password.ErrBreached) {
+		t.Errorf("Register with a breached password: err = %v, want ErrBreached", err)
+	}
+	user := register(t, auth, "user@example.com", "correct horse battery")
+	if _, err := auth.ChangePassword(ctx, user.Token, "correct horse battery", "leaked password", false); !errors.Is(err, password.ErrBreached) {
+		t.Errorf("ChangePassword to a breached password: err = %v, want ErrBreached", err)
+	}
+
+	checker.err = errors.New("connection refused")
+	if _, err := auth.Register(ctx, "Other User", "other@example.com", "staple purple lantern", ""); !errors.Is(err, ErrBreachCheckFailed) {
+		t.Errorf("Register while the check fails closed: err = %v, want ErrBreachCheckFailed", err)
+	}
+
+	auth, _ = newTestAuth(t, func(cfg *Config) {
+		cfg.BreachChecker = checker
+		cfg.BreachFailOpen = true
+	})
+	if _, err := auth.Register(ctx, "Other User", "other@example.com", "staple purple lantern", ""); err != nil {
+		t.Errorf("Register while the check fails open: %v", err)
+	}
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, func(cfg *Config) {
+		cfg.PasswordHasher = testHasher(t, passhash.AlgorithmBcrypt)
+	})
+	user := register(t, auth, "user@example.com", "correct horse battery")
+	legacy := store.users[user.ID].PasswordHash
+	if !bytes.HasPrefix(legacy, []byte("$2a$")) {
+		t.Fatalf("hash = %q, want bcrypt", legacy)
+	}
+
+	if _, err := auth.Login(ctx, "user@example.com", "wrong password", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password: err = %v", err)
+	}
+	auth.hasher = testHasher(t, passhash.AlgorithmArgon2id)
+	if _, err := auth.Login(ctx, "user@example.com", "wrong password", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password: err = %v", err)
+	}
+	if !bytes.Equal(store.users[user.ID].PasswordHash, legacy) {
+		t.Error("a failed login replaced the hash")
+	}
+
+	version := store.users[user.ID].TokenVersion
+	if _, err := auth.Login(ctx, "user@example.com", "correct horse battery", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	upgraded := store.users[user.ID].PasswordHash
+	if !bytes.HasPrefix(upgraded, []byte("$argon2id$")) {
+		t.Errorf("hash after login = %q, want argon2id", upgraded)
+	}
+	if store.users[user.ID].TokenVersion != version {
+		t.Error("the upgrade invalidated issued tokens")
+	}
+	if _, err := auth.GetUser(ctx, user.Token, ""); err != nil {
+		t.Errorf("GetUser with a token issued before the upgrade: %v", err)
+	}
+
+	if _, err := auth.Login(ctx, "user@example.com", "correct horse battery", ""); err != nil {
+		t.Fatalf("Login after the upgrade: %v", err)
+	}
+	if !bytes.Equal(store.users[user.ID].PasswordHash, upgraded) {
+		t.Error("an up to date hash was replaced")
+	}
+}
+
+func TestLoginRotatesPepper(t *testing.T) {
+	ctx := context.Background()
+	keys := map[int][]byte{1: bytes.Repeat([]byte{1}, 32), 2: bytes.Repeat([]byte{2}, 32)}
+	peppered := func(current int) *passhash.Hasher {
+		pepper, err := passhash.NewPepper(current, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := passhash.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+		hasher, err := passhash.New(passhash.AlgorithmArgon2id, params, 0, pepper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hasher
+	}
+
+	auth, store := newTestAuth(t, func(cfg *Config) { cfg.PasswordHasher = peppered(1) })
+	user := register(t, auth, "user@example.com", "correct horse battery")
+	if got := store.users[user.ID].PepperVersion; got != 1 {
+		t.Fatalf("pepper version = %d, want 1", got)
+	}
+
+	auth.hasher = peppered(2)
+	if _, err := auth.Login(ctx, "user@example.com", "correct horse battery", ""); err != nil {
+		t.Fatalf("Login with the old pepper: %v", err)
+	}
+	if got := store.users[user.ID].PepperVersion; got != 2 {
+		t.Errorf("pepper version after login = %d, want 2", got)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "correct horse battery", ""); err != nil {
+		t.Errorf("Login with the new pepper: %v", err)
+	}
+}
+
+func TestLoginVerifyFailure(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "correct horse battery")
+
+	// A hash the hasher cannot read is a server problem, not a wrong password.
+	store.users[user.ID].PasswordHash = []byte("$unknown$hash")
+	for range 5 {
+		_, err := auth.Login(ctx, "user@example.com", "correct horse battery", "")
+		if !errors.Is(err, passhash.ErrUnknownHash) {
+			t.Fatalf("Login: err = %v, want ErrUnknownHash", err)
+		}
+	}
+	if got := store.users[user.ID].FailedAttempts; got != 0 {
+		t.Errorf("failed attempts = %d, want 0", got)
+	}
+	if err := auth.DeleteAccount(ctx, user.Token, "correct horse battery"); errors.Is(err, ErrInvalidCredentials) {
+		t.Error("DeleteAccount reported invalid credentials")
+	}
+	if _, err := auth.ChangePassword(ctx, user.Token, "correct horse battery", "staple purple lantern", false); errors.Is(err, ErrInvalidCredentials) {
+		t.Error("ChangePassword reported invalid credentials")
+	}
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/emailaddr"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/onetime"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+func (auth *Auth) UpdateProfile(ctx context.Context, token string, name string) (*models.UserInfo, error) {
+	const op = "auth.UpdateProfile"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	name, err = auth.normalizeName(name)
+	if err != nil {
+		log.Warn("name rejected", slog.String("reason", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrSaver.UpdateUserName(ctx, claims.Subject, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to update user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.UserInfo{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+	}, nil
+}
+
+// ChangeEmail sends a confirmation link to the new address. The address of
+// the account only changes once the link is followed.
+func (auth *Auth) ChangeEmail(ctx context.Context, token string, newEmail string) error {
+	const op = "auth.ChangeEmail"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.usrProvider.UserByID(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	newEmail, err = auth.newEmail(newEmail)
+	if err != nil {
+		log.Warn("email rejected", slog.String("reason", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := auth.usrProvider.User(ctx, newEmail); err == nil {
+		log.Error("email already taken")
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	} else if !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to check email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	confirmToken, hash, err := onetime.New()
+	if err != nil {
+		log.Error("failed to generate confirmation token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	change := models.EmailChange{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(auth.verificationTTL),
+	}
+	if err := auth.usrSaver.SaveEmailChange(ctx, change); err != nil {
+		log.Error("failed to save email change", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = auth.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Подтверждение нового адреса электронной почты",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nЧтобы подтвердить смену адреса электронной почты, перейдите по ссылке:\n%s\n\nЕсли вы не меняли адрес, просто проигнорируйте это письмо.\n",
+			user.Name, fmt.Sprintf(auth.emailChangeURL, confirmToken),
+		),
+	})
+	if err != nil {
+		log.Error("failed to send confirmation email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email change requested", slog.String("user_id", user.ID))
+	return nil
+}
+
+func (auth *Auth) ConfirmEmailChange(ctx context.Context, token string) error {
+	const op = "auth.ConfirmEmailChange"
+	log := auth.log.With(slog.String("op", op))
+
+	change, err := auth.usrSaver.ConsumeEmailChange(ctx, onetime.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("confirmation token not found", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to consume confirmation token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	previous, err := auth.usrProvider.UserByID(ctx, change.UserID)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	user, err := auth.usrSaver.UpdateUserEmail(ctx, change.UserID, change.NewEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Error("email already taken", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+		log.Error("failed to update email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		UserID:   user.ID,
+		Event:    models.EventEmailChanged,
+		Metadata: map[string]string{"previous_email": previous.Email},
+	}
+	if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", slog.String("error", err.Error()))
+	}
+
+	err = auth.mailer.Send(ctx, mailer.Message{
+		To:      previous.Email,
+		Subject: "Адрес электронной почты изменён",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nАдрес электронной почты вашей учётной записи изменён на %s.\nЕсли это были не вы, срочно восстановите доступ к учётной записи.\n",
+			user.Name, user.Email,
+		),
+	})
+	if err != nil {
+		log.Error("failed to notify previous email", slog.String("error", err.Error()))
+	}
+
+	log.Info("email changed", slog.String("user_id", user.ID))
+	return nil
+}
+
+func (auth *Auth) normalizeName(name string) (string, error) {
+	if auth.names == nil {
+		return name, nil
+	}
+	return auth.names.Normalize(name)
+}
+
+// newEmail normalizes an address a user wants to register or switch to and
+// refuses disposable domains.
+func (auth *Auth) newEmail(email string) (string, error) {
+	if auth.emails == nil {
+		return email, nil
+	}
+	normalized, err := auth.emails.Normalize(email)
+	if err != nil {
+		return "", err
+	}
+	if auth.emails.Disposable(normalized) {
+		return "", emailaddr.ErrDisposable
+	}
+	return normalized, nil
+}
+
+// lookupEmail normalizes an address used to find an existing account.
+// Malformed input is passed through and simply matches no account.
+func (auth *Auth) lookupEmail(email string) string {
+	if auth.emails == nil {
+		return email
+	}
+	if normalized, err := auth.emails.Normalize(email); err == nil {
+		return normalized
+	}
+	return email
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/displayname"
+	"auth-api/internal/lib/emailaddr"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	updated, err := auth.UpdateProfile(ctx, user.Token, "New Name")
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.Name != "New Name" || updated.Email != "user@example.com" {
+		t.Errorf("updated = %+v", updated)
+	}
+	info, err := auth.GetUser(ctx, user.Token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "New Name" {
+		t.Errorf("GetUser name = %q, want New Name", info.Name)
+	}
+
+	if _, err := auth.UpdateProfile(ctx, user.RefreshToken, "Other"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("UpdateProfile with a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	register(t, auth, "taken@example.com", "secret")
+
+	if err := auth.ChangeEmail(ctx, user.Token, "taken@example.com"); !errors.Is(err, storage.ErrUserExists) {
+		t.Errorf("ChangeEmail to a taken address: err = %v, want ErrUserExists", err)
+	}
+
+	if err := auth.ChangeEmail(ctx, user.Token, "new@example.com"); err != nil {
+		t.Fatalf("ChangeEmail: %v", err)
+	}
+	// Nothing changes until the new address is confirmed.
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login with the current address: %v", err)
+	}
+
+	token := store.mailedToken(t, "new@example.com")
+	if err := auth.ConfirmEmailChange(ctx, token); err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+	if _, err := auth.Login(ctx, "new@example.com", "secret", ""); err != nil {
+		t.Errorf("Login with the new address: %v", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with the previous address: err = %v, want ErrInvalidCredentials", err)
+	}
+	changed, err := store.User(ctx, "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.EmailVerifiedAt == nil {
+		t.Error("confirmed address is not marked verified")
+	}
+	if notice := store.lastMail(t, "user@example.com"); !strings.Contains(notice.Body, "new@example.com") {
+		t.Errorf("previous address was not notified: %q", notice.Body)
+	}
+	if got := store.eventCount(models.EventEmailChanged); got != 1 {
+		t.Errorf("email events = %d, want 1", got)
+	}
+
+	if err := auth.ConfirmEmailChange(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ConfirmEmailChange with a used token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestConfirmEmailChangeTaken(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if err := auth.ChangeEmail(ctx, user.Token, "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := store.mailedToken(t, "new@example.com")
+	// Someone registers the address before the link is followed.
+	register(t, auth, "new@example.com", "secret")
+
+	if err := auth.ConfirmEmailChange(ctx, token); !errors.Is(err, storage.ErrUserExists) {
+		t.Errorf("ConfirmEmailChange: err = %v, want ErrUserExists", err)
+	}
+}
+
+func withEmails(cfg *Config) {
+	cfg.Emails = emailaddr.New(true, nil)
+}
+
+func TestEmailNormalization(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t, withEmails)
+
+	user, err := auth.Register(ctx, "Bob", "  Bob@Example.COM ", "correct horse battery", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "bob@example.com" {
+		t.Errorf("registered email = %q, want bob@example.com", user.Email)
+	}
+	if _, err := auth.Register(ctx, "Bob", "bob@example.com", "correct horse battery", ""); !errors.Is(err, storage.ErrUserExists) {
+		t.Errorf("Register with another case: err = %v, want ErrUserExists", err)
+	}
+	if _, err := auth.Login(ctx, "BOB@example.com", "correct horse battery", ""); err != nil {
+		t.Errorf("Login with another case: %v", err)
+	}
+
+	if _, err := auth.Register(ctx, "Eve", "eve@", "correct horse battery", ""); !errors.Is(err, emailaddr.ErrInvalid) {
+		t.Errorf("Register with a malformed email: err = %v, want ErrInvalid", err)
+	}
+	if _, err := auth.Register(ctx, "Eve", "eve@mailinator.com", "correct horse battery", ""); !errors.Is(err, emailaddr.ErrDisposable) {
+		t.Errorf("Register with a disposable email: err = %v, want ErrDisposable", err)
+	}
+	if err := auth.ChangeEmail(ctx, user.Token, "bob@yopmail.com"); !errors.Is(err, emailaddr.ErrDisposable) {
+		t.Errorf("ChangeEmail to a disposable email: err = %v, want ErrDisposable", err)
+	}
+}
+
+func TestNameNormalization(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t, func(cfg *Config) {
+		cfg.Names = displayname.New(2, 16, []string{"admin"})
+	})
+
+	user, err := auth.Register(ctx, " Iv\u200ban   Petrov ", "user@example.com", "correct horse battery", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Ivan Petrov" {
+		t.Errorf("registered name = %q, want %q", user.Name, "Ivan Petrov")
+	}
+	if _, err := auth.Register(ctx, "Аdmin", "other@example.com", "correct horse battery", ""); !errors.Is(err, displayname.ErrReserved) {
+		t.Errorf("Register with a reserved name: err = %v, want ErrReserved", err)
+	}
+
+	if _, err := auth.UpdateProfile(ctx, user.Token, strings.Repeat("x", 17)); !errors.Is(err, displayname.ErrTooLong) {
+		t.Errorf("UpdateProfile with a long name: err = %v, want ErrTooLong", err)
+	}
+	if _, err := auth.UpdateProfile(ctx, user.Token, "Ivan\x07"); !errors.Is(err, displayname.ErrInvalidCharacter) {
+		t.Errorf("UpdateProfile with a control character: err = %v, want ErrInvalidCharacter", err)
+	}
+	info, err := auth.UpdateProfile(ctx, user.Token, "Jose\u0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Jos\u00e9" {
+		t.Errorf("updated name = %q, want it in NFC", info.Name)
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet has 32 characters, so a random byte maps onto it
+	// without bias, and leaves out letters easy to confuse with digits.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz123456789"
+	recoveryCodeLength   = 10
+)
+
+// RegenerateRecoveryCodes replaces the recovery codes of the token owner with
+// a fresh set. Previous codes stop working.
+func (auth *Auth) RegenerateRecoveryCodes(ctx context.Context, token string) ([]string, error) {
+	const op = "auth.RegenerateRecoveryCodes"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	enabled, err := auth.mfaEnabled(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to check mfa", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !enabled {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+	}
+
+	codes, err := auth.issueRecoveryCodes(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to issue recovery codes", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated", slog.String("user_id", claims.Subject))
+	return codes, nil
+}
+
+func (auth *Auth) RecoveryCodesRemaining(ctx context.Context, token string) (int, error) {
+	const op = "auth.RecoveryCodesRemaining"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := auth.usrProvider.CountRecoveryCodes(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to count recovery codes", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+// issueRecoveryCodes stores bcrypt hashes of new codes and returns the codes
+// themselves; they are shown to the user only once.
+func (auth *Auth) issueRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	stored := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		stored = append(stored, models.RecoveryCode{ID: uuid.New().String(), UserID: userID, CodeHash: hash})
+	}
+
+	if err := auth.usrSaver.ReplaceRecoveryCodes(ctx, userID, stored); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkRecoveryCode finds an unused code matching the input and burns it.
+func (auth *Auth) checkRecoveryCode(ctx context.Context, userID string, code string) error {
+	codes, err := auth.usrProvider.UnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	normalized := []byte(normalizeRecoveryCode(code))
+	for _, stored := range codes {
+		if bcrypt.CompareHashAndPassword(stored.CodeHash, normalized) != nil {
+			continue
+		}
+		if err := auth.usrSaver.UseRecoveryCode(ctx, stored.ID); err != nil {
+			return errors.Join(ErrInvalidMFACode, err)
+		}
+
+		event := models.AuditEvent{UserID: userID, Event: models.EventRecoveryCodeUsed}
+		if err := auth.usrSaver.SaveAuditEvent(ctx, event); err != nil {
+			auth.log.Error("failed to save audit event", slog.String("error", err.Error()))
+		}
+		return nil
+	}
+
+	return ErrInvalidMFACode
+}
+
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, c := range raw {
+		if i == recoveryCodeLength/2 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+	}
+	return b.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
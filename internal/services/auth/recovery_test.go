@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodeLogin(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	_, codes := enableTOTP(t, auth, user.Token)
+
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Codes are accepted in any case and without the separator.
+	code := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, code); err != nil {
+		t.Fatalf("VerifyMFA with a recovery code: %v", err)
+	}
+	challenge, err = auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with a used recovery code: err = %v, want ErrInvalidMFACode", err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, "aaaaa-aaaaa"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with an unknown recovery code: err = %v, want ErrInvalidMFACode", err)
+	}
+	if got := store.eventCount(models.EventRecoveryCodeUsed); got != 1 {
+		t.Errorf("recovery events = %d, want 1", got)
+	}
+
+	remaining, err := auth.RecoveryCodesRemaining(ctx, user.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != recoveryCodeCount-1 {
+		t.Errorf("remaining = %d, want %d", remaining, recoveryCodeCount-1)
+	}
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+
+	if _, err := auth.RegenerateRecoveryCodes(ctx, user.Token); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("RegenerateRecoveryCodes without mfa: err = %v, want ErrMFANotEnrolled", err)
+	}
+
+	_, old := enableTOTP(t, auth, user.Token)
+	fresh, err := auth.RegenerateRecoveryCodes(ctx, user.Token)
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if len(fresh) != recoveryCodeCount {
+		t.Errorf("codes = %d, want %d", len(fresh), recoveryCodeCount)
+	}
+
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, old[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA with a replaced code: err = %v, want ErrInvalidMFACode", err)
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, fresh[0]); err != nil {
+		t.Errorf("VerifyMFA with a new code: %v", err)
+	}
+}
+
+func TestNewRecoveryCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, second, ok := strings.Cut(code, "-")
+		if !ok || len(first)+len(second) != recoveryCodeLength {
+			t.Fatalf("code = %q, want two groups of %d characters", code, recoveryCodeLength/2)
+		}
+		if strings.Trim(first+second, recoveryCodeAlphabet) != "" {
+			t.Errorf("code %q has characters outside the alphabet", code)
+		}
+		if isTOTPCode(code) {
+			t.Errorf("code %q looks like a totp code", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeated", code)
+		}
+		seen[code] = true
+	}
+}
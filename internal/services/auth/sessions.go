@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+func (auth *Auth) ListSessions(ctx context.Context, token string) ([]models.Session, error) {
+	const op = "auth.ListSessions"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := auth.usrProvider.UserSessions(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to get sessions", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func (auth *Auth) RevokeSession(ctx context.Context, token string, sessionID string) error {
+	const op = "auth.RevokeSession"
+	log := auth.log.With(slog.String("op", op))
+
+	claims, err := auth.verifyAccessToken(ctx, token, auth.audiences)
+	if err != nil {
+		log.Error("failed to verify access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.RemoveUserSession(ctx, claims.Subject, sessionID); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Error("session not found", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		log.Error("failed to revoke session", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked")
+	return nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientContext is an incoming call from the address with the user agent.
+func clientContext(t *testing.T, ip string, userAgent string) context.Context {
+	t.Helper()
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(ip, "5000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", userAgent))
+}
+
+func TestListSessions(t *testing.T) {
+	auth, _ := newTestAuth(t)
+	laptop := clientContext(t, "203.0.113.7", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/126.0 Safari/537.36")
+	phone := clientContext(t, "198.51.100.1", "okhttp/4.12.0")
+
+	user, err := auth.Register(laptop, "Test User", "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Login(phone, "user@example.com", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Rotation keeps the session.
+	if _, err := auth.Refresh(laptop, user.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := auth.ListSessions(context.Background(), user.Token)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	devices := make(map[string]string)
+	for _, session := range sessions {
+		devices[session.DeviceName] = session.ClientIP
+	}
+	want := map[string]string{"Chrome on Windows": "203.0.113.7", "Android app": "198.51.100.1"}
+	if len(sessions) != len(want) {
+		t.Fatalf("sessions = %+v, want %d", sessions, len(want))
+	}
+	for device, ip := range want {
+		if devices[device] != ip {
+			t.Errorf("session %q from %q, want %q", device, devices[device], ip)
+		}
+	}
+
+	if _, err := auth.ListSessions(context.Background(), user.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ListSessions with a refresh token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	first := register(t, auth, "user@example.com", "secret")
+	before, err := auth.ListSessions(ctx, first.Token)
+	if err != nil || len(before) != 1 {
+		t.Fatalf("ListSessions = %v, %v", before, err)
+	}
+	second, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := register(t, auth, "other@example.com", "secret")
+
+	if err := auth.RevokeSession(ctx, other.Token, before[0].ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession of another user: err = %v, want ErrSessionNotFound", err)
+	}
+
+	if err := auth.RevokeSession(ctx, second.Token, before[0].ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh of the revoked session: err = %v, want ErrInvalidToken", err)
+	}
+	// The access token of the session ends with it, as Introspect reports.
+	if _, err := auth.GetUser(ctx, first.Token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("GetUser with an access token of the revoked session: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Refresh(ctx, second.RefreshToken); err != nil {
+		t.Errorf("Refresh of the remaining session: %v", err)
+	}
+	if err := auth.RevokeSession(ctx, second.Token, before[0].ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession twice: err = %v, want ErrSessionNotFound", err)
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ThrottledError is returned while an account or client address is blocked
+// after too many failed attempts.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many attempts, retry after %s", e.RetryAfter)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// PurgeLoginFailures removes failure counters of accounts and client addresses
+// that can no longer cause a block.
+func (auth *Auth) PurgeLoginFailures(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeLoginFailures"
+
+	accounts, err := auth.accountThrottle.Purge(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	ips, err := auth.ipThrottle.Purge(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return accounts + ips, nil
+}
+
+// checkThrottle refuses the attempt while either the account or the client
+// address is blocked, reporting the longer wait.
+func (auth *Auth) checkThrottle(ctx context.Context, account string, ip string) error {
+	accountWait, err := auth.accountThrottle.Wait(ctx, account)
+	if err != nil {
+		return err
+	}
+	ipWait, err := auth.ipThrottle.Wait(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	if wait := max(accountWait, ipWait); wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (auth *Auth) recordFailure(ctx context.Context, log *slog.Logger, account string, ip string) {
+	if delay, err := auth.accountThrottle.Fail(ctx, account); err != nil {
+		log.Error("failed to record failed attempt", slog.String("error", err.Error()))
+	} else if delay > 0 {
+		log.Warn("account throttled", slog.String("account", account), slog.Duration("delay", delay))
+	}
+
+	if delay, err := auth.ipThrottle.Fail(ctx, ip); err != nil {
+		log.Error("failed to record failed attempt", slog.String("error", err.Error()))
+	} else if delay > 0 {
+		log.Warn("client address throttled", slog.String("ip", ip), slog.Duration("delay", delay))
+	}
+}
+
+func (auth *Auth) resetFailures(ctx context.Context, log *slog.Logger, account string) {
+	if err := auth.accountThrottle.Reset(ctx, account); err != nil {
+		log.Error("failed to reset failed attempts", slog.String("error", err.Error()))
+	}
+}
+
+func throttleAccount(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	register(t, auth, "user@example.com", "secret")
+	register(t, auth, "other@example.com", "secret")
+
+	// A successful login starts the count over.
+	for range 2 {
+		if _, err := auth.Login(ctx, "user@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login with a wrong password: err = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	for range 3 {
+		if _, err := auth.Login(ctx, "user@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login with a wrong password: err = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	// The account stays blocked for the right password and any spelling
+	// of the address.
+	for _, email := range []string{"user@example.com", " USER@example.com"} {
+		_, err := auth.Login(ctx, email, "secret", "")
+		var throttled *ThrottledError
+		if !errors.As(err, &throttled) || !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("Login(%q) while blocked: err = %v, want ThrottledError", email, err)
+		}
+		if throttled.RetryAfter <= 0 {
+			t.Errorf("RetryAfter = %v, want a positive wait", throttled.RetryAfter)
+		}
+	}
+
+	if _, err := auth.Login(ctx, "other@example.com", "secret", ""); err != nil {
+		t.Errorf("Login of another account: %v", err)
+	}
+}
+
+func TestLoginThrottleByAddress(t *testing.T) {
+	auth, _ := newTestAuth(t, func(cfg *Config) {
+		cfg.AccountThrottle, cfg.IPThrottle = testThrottles(10, 3)
+	})
+	register(t, auth, "user@example.com", "secret")
+	attacker := clientContext(t, "203.0.113.7", "curl/8.0")
+	user := clientContext(t, "198.51.100.1", "curl/8.0")
+
+	// Unknown accounts count against the address too.
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := auth.Login(attacker, email, "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login(%s): err = %v, want ErrInvalidCredentials", email, err)
+		}
+	}
+	if _, err := auth.Login(attacker, "user@example.com", "secret", ""); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login from a blocked address: err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := auth.Login(user, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login from another address: %v", err)
+	}
+}
+
+func TestVerifyMFAThrottle(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuth(t)
+	user := register(t, auth, "user@example.com", "secret")
+	secret, _ := enableTOTP(t, auth, user.Token)
+
+	challenge, err := auth.Login(ctx, "user@example.com", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("VerifyMFA with a wrong code: err = %v, want ErrInvalidMFACode", err)
+		}
+	}
+	if _, err := auth.VerifyMFA(ctx, challenge.MFAToken, totpCode(t, secret, 0)); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("VerifyMFA while blocked: err = %v, want ErrTooManyAttempts", err)
+	}
+	// Wrong codes count against the same account as wrong passwords.
+	if _, err := auth.Login(ctx, "User@Example.com", "secret", ""); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Login after wrong mfa codes: err = %v, want ErrTooManyAttempts", err)
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/lib/mailer"
+	"auth-api/internal/lib/onetime"
+	"auth-api/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SendVerificationEmail sends a new verification link. It succeeds silently
+// for unknown or already verified addresses to avoid revealing accounts.
+func (auth *Auth) SendVerificationEmail(ctx context.Context, email string) error {
+	const op = "auth.SendVerificationEmail"
+	log := auth.log.With(slog.String("op", op))
+
+	user, err := auth.usrProvider.User(ctx, auth.lookupEmail(email))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("verification requested for unknown email")
+			return nil
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The check, save and send run in the background, so a known address
+	// answers as fast as an unknown one and failures do not tell them apart.
+	auth.background.Add(1)
+	go func() {
+		defer auth.background.Done()
+
+		if user.EmailVerifiedAt != nil {
+			log.Info("email already verified", slog.String("user_id", user.ID))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundTimeout)
+		defer cancel()
+		if err := auth.sendVerification(ctx, user); err != nil {
+			log.Error("failed to send verification email", slog.String("error", err.Error()))
+		}
+	}()
+	return nil
+}
+
+func (auth *Auth) VerifyEmail(ctx context.Context, token string) error {
+	const op = "auth.VerifyEmail"
+	log := auth.log.With(slog.String("op", op))
+
+	verification, err := auth.usrSaver.ConsumeEmailVerification(ctx, onetime.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("verification token not found", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to consume verification token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.usrSaver.MarkEmailVerified(ctx, verification.UserID, verification.Email); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("email changed since verification was sent", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to mark email verified", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified", slog.String("user_id", verification.UserID))
+	return nil
+}
+
+func (auth *Auth) sendVerification(ctx context.Context, user *models.UserModel) error {
+	token, hash, err := onetime.New()
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(auth.verificationTTL),
+	}
+	if err := auth.usrSaver.SaveEmailVerification(ctx, verification); err != nil {
+		return err
+	}
+
+	return auth.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение адреса электронной почты",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nЧтобы подтвердить адрес электронной почты, перейдите по ссылке:\n%s\n\nЕсли вы не регистрировались, просто проигнорируйте это письмо.\n",
+			user.Name, fmt.Sprintf(auth.verificationURL, token),
+		),
+	})
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	register(t, auth, "user@example.com", "secret")
+	token := store.mailedToken(t, "user@example.com")
+
+	if err := auth.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	user, err := store.User(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email is not marked verified")
+	}
+
+	for _, token := range []string{token, "not a token"} {
+		if err := auth.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyEmail(%q): err = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
+
+func TestVerifyEmailExpired(t *testing.T) {
+	auth, store := newTestAuth(t, func(cfg *Config) { cfg.VerificationTTL = -time.Second })
+	register(t, auth, "user@example.com", "secret")
+
+	if err := auth.VerifyEmail(context.Background(), store.mailedToken(t, "user@example.com")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyEmail with an expired token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t)
+	register(t, auth, "user@example.com", "secret")
+	first := store.mailedToken(t, "user@example.com")
+
+	if err := auth.SendVerificationEmail(ctx, "user@example.com"); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	auth.Wait()
+	second := store.mailedToken(t, "user@example.com")
+
+	// Only the latest link works.
+	if err := auth.VerifyEmail(ctx, first); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyEmail with a replaced token: err = %v, want ErrInvalidToken", err)
+	}
+	if err := auth.VerifyEmail(ctx, second); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+
+	// Unknown and verified addresses succeed without sending anything.
+	sent := store.mailCount()
+	for _, email := range []string{"user@example.com", "unknown@example.com"} {
+		if err := auth.SendVerificationEmail(ctx, email); err != nil {
+			t.Errorf("SendVerificationEmail(%s): %v", email, err)
+		}
+	}
+	auth.Wait()
+	if store.mailCount() != sent {
+		t.Errorf("mails = %d, want %d", store.mailCount(), sent)
+	}
+
+	// A failing mailer is only logged, the caller sees the same answer as
+	// for an unknown address.
+	register(t, auth, "other@example.com", "secret")
+	store.sendErr = errors.New("smtp is down")
+	if err := auth.SendVerificationEmail(ctx, "other@example.com"); err != nil {
+		t.Errorf("SendVerificationEmail with a failing mailer: %v", err)
+	}
+	auth.Wait()
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	auth, store := newTestAuth(t, func(cfg *Config) { cfg.RequireVerifiedEmail = true })
+
+	user := register(t, auth, "user@example.com", "secret")
+	if user.Token != "" || user.RefreshToken != "" {
+		t.Errorf("Register issued tokens for an unverified email: %+v", user)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("Login before verification: err = %v, want ErrEmailNotVerified", err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with a wrong password: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	if err := auth.VerifyEmail(ctx, store.mailedToken(t, "user@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Login(ctx, "user@example.com", "secret", ""); err != nil {
+		t.Errorf("Login after verification: %v", err)
+	}
+}
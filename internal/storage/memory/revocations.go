@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Minute
+
+type RevocationStore interface {
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	AccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Revocations keeps revoked access token ids in memory until the tokens
+// expire. With a backing store it acts as a cache in front of it: revocations
+// are written through and positive lookups are remembered for ttl, which
+// should be the access token lifetime. Misses are always checked against the
+// backing store since other instances may revoke tokens.
+type Revocations struct {
+	mu        sync.RWMutex
+	revoked   map[string]time.Time
+	next      RevocationStore
+	ttl       time.Duration
+	lastPrune time.Time
+}
+
+func NewRevocations(next RevocationStore, ttl time.Duration) *Revocations {
+	return &Revocations{
+		revoked:   make(map[string]time.Time),
+		next:      next,
+		ttl:       ttl,
+		lastPrune: time.Now(),
+	}
+}
+
+func (r *Revocations) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if r.next != nil {
+		if err := r.next.RevokeAccessToken(ctx, jti, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoked[jti] = expiresAt
+	if now := time.Now(); now.Sub(r.lastPrune) > pruneInterval {
+		r.prune(now)
+	}
+	return nil
+}
+
+func (r *Revocations) AccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.RLock()
+	expiresAt, ok := r.revoked[jti]
+	r.mu.RUnlock()
+
+	if ok && time.Now().Before(expiresAt) {
+		return true, nil
+	}
+	if r.next == nil {
+		return false, nil
+	}
+
+	revoked, err := r.next.AccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		r.mu.Lock()
+		r.revoked[jti] = time.Now().Add(r.ttl)
+		r.mu.Unlock()
+	}
+	return revoked, nil
+}
+
+func (r *Revocations) prune(now time.Time) {
+	for jti, expiresAt := range r.revoked {
+		if !now.Before(expiresAt) {
+			delete(r.revoked, jti)
+		}
+	}
+	r.lastPrune = now
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeStore counts lookups so tests can tell cache hits from misses.
+type fakeStore struct {
+	revoked map[string]time.Time
+	lookups int
+	err     error
+}
+
+func (s *fakeStore) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *fakeStore) AccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.lookups++
+	if s.err != nil {
+		return false, s.err
+	}
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func TestRevocations(t *testing.T) {
+	ctx := context.Background()
+	r := NewRevocations(nil, time.Minute)
+
+	if err := r.RevokeAccessToken(ctx, "active", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		jti  string
+		want bool
+	}{
+		{"active", true},
+		{"expired", false},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		if got, err := r.AccessTokenRevoked(ctx, tt.jti); err != nil || got != tt.want {
+			t.Errorf("AccessTokenRevoked(%s) = %v, %v, want %v", tt.jti, got, err, tt.want)
+		}
+	}
+}
+
+func TestRevocationsPrune(t *testing.T) {
+	ctx := context.Background()
+	r := NewRevocations(nil, time.Minute)
+
+	if err := r.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeAccessToken(ctx, "active", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// Expired entries wait for the next prune instead of a scan per call.
+	if _, ok := r.revoked["expired"]; !ok {
+		t.Fatal("expired entry pruned before the interval")
+	}
+
+	r.lastPrune = time.Now().Add(-2 * pruneInterval)
+	if err := r.RevokeAccessToken(ctx, "another", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.revoked["expired"]; ok {
+		t.Error("expired entry was kept")
+	}
+	if _, ok := r.revoked["active"]; !ok {
+		t.Error("active entry was pruned")
+	}
+}
+
+func TestRevocationsCache(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{revoked: make(map[string]time.Time)}
+	r := NewRevocations(store, time.Minute)
+
+	if err := r.RevokeAccessToken(ctx, "local", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.revoked["local"]; !ok {
+		t.Error("revocation was not written through")
+	}
+
+	// Another instance revoked this one.
+	store.revoked["remote"] = time.Now().Add(time.Minute)
+	for range 2 {
+		if revoked, err := r.AccessTokenRevoked(ctx, "remote"); err != nil || !revoked {
+			t.Fatalf("AccessTokenRevoked(remote) = %v, %v, want true", revoked, err)
+		}
+	}
+	if revoked, err := r.AccessTokenRevoked(ctx, "local"); err != nil || !revoked {
+		t.Fatalf("AccessTokenRevoked(local) = %v, %v, want true", revoked, err)
+	}
+	if store.lookups != 1 {
+		t.Errorf("store lookups = %d, want 1: positives are cached", store.lookups)
+	}
+
+	for range 2 {
+		if revoked, err := r.AccessTokenRevoked(ctx, "unknown"); err != nil || revoked {
+			t.Fatalf("AccessTokenRevoked(unknown) = %v, %v, want false", revoked, err)
+		}
+	}
+	if store.lookups != 3 {
+		t.Errorf("store lookups = %d, want 3: misses are not cached", store.lookups)
+	}
+
+	store.err = errors.New("connection refused")
+	if err := r.RevokeAccessToken(ctx, "failed", time.Now().Add(time.Minute)); err == nil {
+		t.Error("RevokeAccessToken ignored the store error")
+	}
+	if _, err := r.AccessTokenRevoked(ctx, "failed"); err == nil {
+		t.Error("AccessTokenRevoked ignored the store error")
+	}
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type loginAttempts struct {
+	failures     int
+	lastFailedAt time.Time
+	blockedUntil time.Time
+}
+
+// LoginAttempts counts failed logins in process. It only protects a single
+// instance; deployments with several replicas should use the PostgreSQL
+// store. Stale counters stay until PurgeLoginFailures drops them.
+type LoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+}
+
+func NewLoginAttempts() *LoginAttempts {
+	return &LoginAttempts{attempts: make(map[string]*loginAttempts)}
+}
+
+func (l *LoginAttempts) LoginBlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if attempts, ok := l.attempts[key]; ok {
+		return attempts.blockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (l *LoginAttempts) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	attempts, ok := l.attempts[key]
+	if !ok || now.Sub(attempts.lastFailedAt) > window {
+		attempts = &loginAttempts{blockedUntil: l.blockedUntil(key)}
+		l.attempts[key] = attempts
+	}
+	attempts.failures++
+	attempts.lastFailedAt = now
+
+	return attempts.failures, nil
+}
+
+func (l *LoginAttempts) BlockLogin(ctx context.Context, key string, until time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if attempts, ok := l.attempts[key]; ok {
+		attempts.blockedUntil = until
+	}
+	return nil
+}
+
+func (l *LoginAttempts) ResetLoginFailures(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+	return nil
+}
+
+func (l *LoginAttempts) PurgeLoginFailures(ctx context.Context, prefix string, window time.Duration) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.prune(time.Now(), prefix, window), nil
+}
+
+func (l *LoginAttempts) blockedUntil(key string) time.Time {
+	if attempts, ok := l.attempts[key]; ok {
+		return attempts.blockedUntil
+	}
+	return time.Time{}
+}
+
+// prune drops keys under prefix whose failures fell out of the window and
+// whose block has passed.
+func (l *LoginAttempts) prune(now time.Time, prefix string, window time.Duration) int64 {
+	var pruned int64
+	for key, attempts := range l.attempts {
+		if strings.HasPrefix(key, prefix) && now.Sub(attempts.lastFailedAt) > window && !now.Before(attempts.blockedUntil) {
+			delete(l.attempts, key)
+			pruned++
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoginAttempts(t *testing.T) {
+	ctx := context.Background()
+	attempts := NewLoginAttempts()
+
+	for want := 1; want <= 3; want++ {
+		got, err := attempts.RecordLoginFailure(ctx, "account:user", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("failures = %d, want %d", got, want)
+		}
+	}
+
+	until := time.Now().Add(time.Minute)
+	if err := attempts.BlockLogin(ctx, "account:user", until); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := attempts.LoginBlockedUntil(ctx, "account:user"); !got.Equal(until) {
+		t.Errorf("blocked until %v, want %v", got, until)
+	}
+	if got, _ := attempts.LoginBlockedUntil(ctx, "account:other"); !got.IsZero() {
+		t.Errorf("other key blocked until %v", got)
+	}
+
+	if err := attempts.ResetLoginFailures(ctx, "account:user"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := attempts.LoginBlockedUntil(ctx, "account:user"); !got.IsZero() {
+		t.Errorf("blocked until %v after reset", got)
+	}
+	if got, _ := attempts.RecordLoginFailure(ctx, "account:user", time.Hour); got != 1 {
+		t.Errorf("failures after reset = %d, want 1", got)
+	}
+}
+
+func TestLoginAttemptsWindow(t *testing.T) {
+	ctx := context.Background()
+	attempts := NewLoginAttempts()
+
+	if _, err := attempts.RecordLoginFailure(ctx, "ip:203.0.113.7", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	until := time.Now().Add(time.Minute)
+	if err := attempts.BlockLogin(ctx, "ip:203.0.113.7", until); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Failures outside the window start over, but a running block is kept.
+	got, err := attempts.RecordLoginFailure(ctx, "ip:203.0.113.7", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Errorf("failures = %d, want 1", got)
+	}
+	if blocked, _ := attempts.LoginBlockedUntil(ctx, "ip:203.0.113.7"); !blocked.Equal(until) {
+		t.Errorf("blocked until %v, want %v", blocked, until)
+	}
+}
+
+func TestPurgeLoginFailures(t *testing.T) {
+	ctx := context.Background()
+	attempts := NewLoginAttempts()
+
+	for _, key := range []string{"ip:203.0.113.7", "ip:203.0.113.8", "account:user"} {
+		if _, err := attempts.RecordLoginFailure(ctx, key, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := attempts.BlockLogin(ctx, "ip:203.0.113.8", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Only stale counters under the prefix go, a running block is kept.
+	purged, err := attempts.PurgeLoginFailures(ctx, "ip:", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged = %d, want 1", purged)
+	}
+	for key, want := range map[string]bool{"ip:203.0.113.7": false, "ip:203.0.113.8": true, "account:user": true} {
+		if _, ok := attempts.attempts[key]; ok != want {
+			t.Errorf("%s kept = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SoftDeleteUser hides the user from every lookup and ends all sessions. The
+// address is free for a new account right away, the unique index only covers
+// live rows. The row itself is removed later by PurgeDeletedUsers.
+func (s *s) SoftDeleteUser(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SoftDeleteUser: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+		UPDATE users SET deleted_at = now(), token_version = token_version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("SoftDeleteUser: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("SoftDeleteUser: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedUsers removes users soft deleted before the given time together
+// with all their rows in dependent tables.
+func (s *s) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const query = `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	res, err := s.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeDeletedUsers: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *s) UserAuditEvents(ctx context.Context, userID string) ([]models.AuditEvent, error) {
+	const query = `
+		SELECT user_id, event, metadata, created_at
+		FROM audit_events
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UserAuditEvents: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var (
+			event    models.AuditEvent
+			metadata []byte
+		)
+		if err := rows.Scan(&event.UserID, &event.Event, &metadata, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("UserAuditEvents: %w", err)
+		}
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, fmt.Errorf("UserAuditEvents: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserAuditEvents: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,86 @@
+package postgresql
+
+import (
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecordFailedLogin counts a wrong password for the user. Reaching
+// maxAttempts consecutive failures locks the account for lockFor and starts
+// the count over. The returned time is set when the account is locked.
+func (s *s) RecordFailedLogin(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) (*time.Time, error) {
+	const query = `
+		UPDATE users
+		SET failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE locked_until END
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING locked_until`
+
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, userID, maxAttempts, lockFor.Seconds()).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("RecordFailedLogin: %w", err)
+	}
+	if !lockedUntil.Valid || !lockedUntil.Time.After(time.Now()) {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+func (s *s) ResetFailedLogins(ctx context.Context, userID string) error {
+	const query = `UPDATE users SET failed_attempts = 0 WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("ResetFailedLogins: %w", err)
+	}
+	return nil
+}
+
+// LockUser locks the account until the given time and ends all its
+// sessions.
+func (s *s) LockUser(ctx context.Context, userID string, until time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("LockUser: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+		UPDATE users SET locked_until = $2, token_version = token_version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, userID, until)
+	if err != nil {
+		return fmt.Errorf("LockUser: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("LockUser: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *s) UnlockUser(ctx context.Context, userID string) error {
+	const query = `
+		UPDATE users SET locked_until = NULL, failed_attempts = 0
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("UnlockUser: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveTOTP stores a new, not yet confirmed secret, replacing a previous
+// unfinished enrollment.
+func (s *s) SaveTOTP(ctx context.Context, totp models.TOTP) error {
+	const query = `
+		INSERT INTO user_totp (user_id, secret_encrypted, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted,
+			confirmed_at = NULL,
+			last_used_step = 0,
+			created_at = EXCLUDED.created_at`
+
+	if _, err := s.db.ExecContext(ctx, query, totp.UserID, totp.SecretEncrypted, time.Now().UTC()); err != nil {
+		return fmt.Errorf("SaveTOTP: %w", err)
+	}
+	return nil
+}
+
+func (s *s) UserTOTP(ctx context.Context, userID string) (*models.TOTP, error) {
+	const query = `SELECT user_id, secret_encrypted, confirmed_at, last_used_step FROM user_totp WHERE user_id = $1`
+
+	var (
+		totp        models.TOTP
+		confirmedAt sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, query, userID).
+		Scan(&totp.UserID, &totp.SecretEncrypted, &confirmedAt, &totp.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrMFANotFound
+		}
+		return nil, fmt.Errorf("UserTOTP: %w", err)
+	}
+	if confirmedAt.Valid {
+		totp.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &totp, nil
+}
+
+// UseTOTPStep confirms the enrollment if needed and records the time step of
+// an accepted code. A step at or before the last recorded one is refused, so
+// every code works only once.
+func (s *s) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	const query = `
+		UPDATE user_totp
+		SET last_used_step = $2, confirmed_at = COALESCE(confirmed_at, now())
+		WHERE user_id = $1 AND last_used_step < $2`
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("UseTOTPStep: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrMFACodeUsed
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes drops every previous recovery code of the user and
+// stores the new set.
+func (s *s) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []models.RecoveryCode) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ReplaceRecoveryCodes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("ReplaceRecoveryCodes: %w", err)
+	}
+
+	const query = `INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)`
+	now := time.Now().UTC()
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx, query, code.ID, userID, code.CodeHash, now); err != nil {
+			return fmt.Errorf("ReplaceRecoveryCodes: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *s) UnusedRecoveryCodes(ctx context.Context, userID string) ([]models.RecoveryCode, error) {
+	const query = `SELECT id, user_id, code_hash FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UnusedRecoveryCodes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []models.RecoveryCode
+	for rows.Next() {
+		var code models.RecoveryCode
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash); err != nil {
+			return nil, fmt.Errorf("UnusedRecoveryCodes: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UnusedRecoveryCodes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (s *s) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	const query = `SELECT count(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("CountRecoveryCodes: %w", err)
+	}
+	return count, nil
+}
+
+// UseRecoveryCode marks the code as used. A code that was already used in a
+// concurrent request is reported as storage.ErrMFACodeUsed.
+func (s *s) UseRecoveryCode(ctx context.Context, codeID string) error {
+	const query = `UPDATE mfa_recovery_codes SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, codeID)
+	if err != nil {
+		return fmt.Errorf("UseRecoveryCode: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrMFACodeUsed
+	}
+	return nil
+}
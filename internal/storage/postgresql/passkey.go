@@ -0,0 +1,147 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *s) SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error {
+	const query = `
+		INSERT INTO webauthn_challenges (challenge, user_id, ceremony, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		challenge.Challenge, nullString(challenge.UserID), challenge.Ceremony, challenge.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("SaveWebAuthnChallenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnChallenge deletes the challenge so it can be answered only
+// once. Expired challenges and challenges of another ceremony are reported
+// as storage.ErrTokenNotFound.
+func (s *s) ConsumeWebAuthnChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error) {
+	const query = `
+		DELETE FROM webauthn_challenges
+		WHERE challenge = $1
+		RETURNING challenge, user_id, ceremony, expires_at`
+
+	var (
+		stored models.WebAuthnChallenge
+		userID sql.NullString
+	)
+	err := s.db.QueryRowContext(ctx, query, challenge).
+		Scan(&stored.Challenge, &userID, &stored.Ceremony, &stored.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("ConsumeWebAuthnChallenge: %w", err)
+	}
+	if stored.Ceremony != ceremony || !stored.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+	stored.UserID = userID.String
+
+	return &stored, nil
+}
+
+func (s *s) PurgeWebAuthnChallenges(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	const query = `DELETE FROM webauthn_challenges WHERE expires_at < $1`
+
+	res, err := s.db.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeWebAuthnChallenges: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *s) SavePasskey(ctx context.Context, passkey models.Passkey) error {
+	const query = `
+		INSERT INTO webauthn_credentials (id, user_id, public_key, sign_count, aaguid, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		passkey.ID, passkey.UserID, passkey.PublicKey, int64(passkey.SignCount), passkey.AAGUID, passkey.Name, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrPasskeyExists
+		}
+		return fmt.Errorf("SavePasskey: %w", err)
+	}
+	return nil
+}
+
+const passkeyColumns = `id, user_id, public_key, sign_count, aaguid, name, created_at, last_used_at`
+
+func scanPasskey(row scanner) (*models.Passkey, error) {
+	var (
+		passkey    models.Passkey
+		signCount  int64
+		lastUsedAt sql.NullTime
+	)
+	err := row.Scan(
+		&passkey.ID, &passkey.UserID, &passkey.PublicKey, &signCount,
+		&passkey.AAGUID, &passkey.Name, &passkey.CreatedAt, &lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	passkey.SignCount = uint32(signCount)
+	if lastUsedAt.Valid {
+		passkey.LastUsedAt = &lastUsedAt.Time
+	}
+	return &passkey, nil
+}
+
+func (s *s) Passkey(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	const query = `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE id = $1`
+
+	passkey, err := scanPasskey(s.db.QueryRowContext(ctx, query, credentialID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrPasskeyNotFound
+		}
+		return nil, fmt.Errorf("Passkey: %w", err)
+	}
+	return passkey, nil
+}
+
+func (s *s) UserPasskeys(ctx context.Context, userID string) ([]models.Passkey, error) {
+	const query = `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UserPasskeys: %w", err)
+	}
+	defer rows.Close()
+
+	var passkeys []models.Passkey
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("UserPasskeys: %w", err)
+		}
+		passkeys = append(passkeys, *passkey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserPasskeys: %w", err)
+	}
+
+	return passkeys, nil
+}
+
+func (s *s) UpdatePasskeySignCount(ctx context.Context, credentialID []byte, signCount uint32) error {
+	const query = `UPDATE webauthn_credentials SET sign_count = $2, last_used_at = now() WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, credentialID, int64(signCount)); err != nil {
+		return fmt.Errorf("UpdatePasskeySignCount: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SavePasswordReset replaces any pending reset of the user, so only the most
+// recently sent token works.
+func (s *s) SavePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SavePasswordReset: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, reset.UserID); err != nil {
+		return fmt.Errorf("SavePasswordReset: %w", err)
+	}
+
+	const query = `
+		INSERT INTO password_reset_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, reset.TokenHash, reset.UserID, reset.ExpiresAt, time.Now().UTC()); err != nil {
+		return fmt.Errorf("SavePasswordReset: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *s) PasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error) {
+	const query = `SELECT user_id, token_hash, expires_at FROM password_reset_tokens WHERE token_hash = $1`
+
+	var reset models.PasswordReset
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&reset.UserID, &reset.TokenHash, &reset.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("PasswordReset: %w", err)
+	}
+	if !reset.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	return &reset, nil
+}
+
+func (s *s) ConsumePasswordReset(ctx context.Context, tokenHash []byte) (*models.PasswordReset, error) {
+	const query = `
+		DELETE FROM password_reset_tokens
+		WHERE token_hash = $1
+		RETURNING user_id, token_hash, expires_at`
+
+	var reset models.PasswordReset
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&reset.UserID, &reset.TokenHash, &reset.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("ConsumePasswordReset: %w", err)
+	}
+	if !reset.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	return &reset, nil
+}
+
+// UpgradePasswordHash replaces a hash of the same password, so unlike
+// UpdatePassword it keeps tokens valid. It is a no-op when the password was
+// changed since oldHash was read.
+func (s *s) UpgradePasswordHash(ctx context.Context, userID string, oldHash []byte, newHash []byte, pepper int) error {
+	const query = `UPDATE users SET password_hash = $3, pepper_version = $4 WHERE id = $1 AND password_hash = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, userID, oldHash, newHash, pepper); err != nil {
+		return fmt.Errorf("UpgradePasswordHash: %w", err)
+	}
+	return nil
+}
+
+// UpdatePassword stores the new hash and bumps token_version, which
+// invalidates every access token issued before the change.
+func (s *s) UpdatePassword(ctx context.Context, userID string, passHash []byte, pepper int) error {
+	const query = `UPDATE users SET password_hash = $2, pepper_version = $3, token_version = token_version + 1 WHERE id = $1 AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, passHash, pepper)
+	if err != nil {
+		return fmt.Errorf("UpdatePassword: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -57,22 +58,41 @@ func (s *s) Stop() error {
 	return s.db.Close()
 }
 
-func (s *s) CreateUser(ctx context.Context, name string, email string, passHash []byte) (*models.UserModel, error) {
+const userColumns = `id, email, name, password_hash, pepper_version, created_at, email_verified_at, token_version, failed_attempts, locked_until`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (*models.UserModel, error) {
+	var (
+		user        models.UserModel
+		verifiedAt  sql.NullTime
+		lockedUntil sql.NullTime
+	)
+	if err := row.Scan(
+		&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.PepperVersion, &user.CreatedAt, &verifiedAt, &user.TokenVersion,
+		&user.FailedAttempts, &lockedUntil,
+	); err != nil {
+		return nil, err
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	return &user, nil
+}
+
+func (s *s) CreateUser(ctx context.Context, name string, email string, passHash []byte, pepper int) (*models.UserModel, error) {
 	const query = `
-		INSERT INTO users (email, name, password_hash, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, email, name, password_hash, created_at`
+		INSERT INTO users (email, name, password_hash, pepper_version, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + userColumns
 
 	createdAt := time.Now().UTC()
-	// row := s.db.QueryRowContext(ctx, query, email, name, passHash, createdAt)
-
-	// var user models.UserModel
-	// if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.CreatedAt); err != nil {
-	// 	return nil, fmt.Errorf("CreateUser: %w", err)
-	// }
-	var user models.UserModel
-	err := s.db.QueryRowContext(ctx, query, email, name, passHash, createdAt).
-		Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.CreatedAt)
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, email, name, passHash, pepper, createdAt))
 	if err != nil {
 		if isUniqueViolation(err) {
 			return nil, storage.ErrUserExists
@@ -80,21 +100,39 @@ func (s *s) CreateUser(ctx context.Context, name string, email string, passHash
 		return nil, fmt.Errorf("CreateUser: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (s *s) SaveRefreshToken(ctx context.Context, tokenID string, userID string, expiresAt time.Time) error {
+func (s *s) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
 	const query = `
-		INSERT INTO refresh_tokens (token_id, user_id, expires_at, created_at)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO refresh_tokens (
+			token_id, user_id, family_id, parent_token_id, user_agent, client_ip, device_name,
+			expires_at, created_at, last_used_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)`
 
-	_, err := s.db.ExecContext(ctx, query, tokenID, userID, expiresAt, time.Now().UTC())
+	_, err := s.db.ExecContext(ctx, query,
+		token.TokenID, token.UserID, token.FamilyID, nullString(token.ParentTokenID),
+		token.UserAgent, token.ClientIP, token.DeviceName, token.ExpiresAt, time.Now().UTC(),
+	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return storage.ErrTokenSaveFailed
 	}
 	return err
 }
 
+func (s *s) RotateRefreshToken(ctx context.Context, tokenID string) error {
+	const query = `UPDATE refresh_tokens SET rotated_at = now() WHERE token_id = $1 AND rotated_at IS NULL`
+	res, err := s.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrTokenNotFound
+	}
+	return nil
+}
+
 func (s *s) RemoveRefreshToken(ctx context.Context, tokenID string) error {
 	const query = `DELETE FROM refresh_tokens WHERE token_id = $1`
 	_, err := s.db.ExecContext(ctx, query, tokenID)
@@ -104,49 +142,205 @@ func (s *s) RemoveRefreshToken(ctx context.Context, tokenID string) error {
 	return err
 }
 
+func (s *s) RemoveRefreshTokenFamily(ctx context.Context, familyID string) error {
+	const query = `DELETE FROM refresh_tokens WHERE family_id = $1`
+	if _, err := s.db.ExecContext(ctx, query, familyID); err != nil {
+		return fmt.Errorf("RemoveRefreshTokenFamily: %w", err)
+	}
+	return nil
+}
+
+func (s *s) RemoveUserRefreshTokens(ctx context.Context, userID string) error {
+	const query = `DELETE FROM refresh_tokens WHERE user_id = $1`
+	if _, err := s.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("RemoveUserRefreshTokens: %w", err)
+	}
+	return nil
+}
+
+func (s *s) RemoveUserSession(ctx context.Context, userID string, sessionID string) error {
+	const query = `DELETE FROM refresh_tokens WHERE user_id = $1 AND family_id = $2`
+	res, err := s.db.ExecContext(ctx, query, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("RemoveUserSession: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrSessionNotFound
+	}
+	return nil
+}
+
 func (s *s) User(ctx context.Context, email string) (*models.UserModel, error) {
-	const query = `SELECT id, email, name, password_hash, created_at FROM users WHERE email = $1`
-	row := s.db.QueryRowContext(ctx, query, email)
+	const query = `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
 
-	var user models.UserModel
-	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.CreatedAt); err != nil {
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, email))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("User: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 func (s *s) UserByID(ctx context.Context, userID string) (*models.UserModel, error) {
-	const query = `SELECT id, email, name, password_hash, created_at FROM users WHERE id = $1`
-	row := s.db.QueryRowContext(ctx, query, userID)
+	const query = `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
 
-	var user models.UserModel
-	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.CreatedAt); err != nil {
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("UserByID: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+func (s *s) TokenVersion(ctx context.Context, userID string) (int, error) {
+	const query = `SELECT token_version FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrUserNotFound
+		}
+		return 0, fmt.Errorf("TokenVersion: %w", err)
+	}
+	return version, nil
 }
 
-func (s *s) RefreshToken(ctx context.Context, tokenID string) (string, error) {
-	const query = `SELECT user_id FROM refresh_tokens WHERE token_id = $1 AND expires_at > now()`
+func (s *s) RefreshToken(ctx context.Context, tokenID string) (*models.RefreshToken, error) {
+	const query = `
+		SELECT token_id, user_id, family_id, parent_token_id, user_agent, client_ip, device_name,
+			expires_at, created_at, last_used_at, rotated_at
+		FROM refresh_tokens
+		WHERE token_id = $1 AND expires_at > now()`
 	row := s.db.QueryRowContext(ctx, query, tokenID)
 
-	var userID string
-	if err := row.Scan(&userID); err != nil {
+	var (
+		token     models.RefreshToken
+		parentID  sql.NullString
+		rotatedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&token.TokenID, &token.UserID, &token.FamilyID, &parentID, &token.UserAgent, &token.ClientIP, &token.DeviceName,
+		&token.ExpiresAt, &token.CreatedAt, &token.LastUsedAt, &rotatedAt,
+	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrUserNotFound
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("RefreshToken: %w", err)
+	}
+	token.ParentTokenID = parentID.String
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+
+	return &token, nil
+}
+
+func (s *s) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE family_id = $1 AND rotated_at IS NULL AND expires_at > now()
+		)`
+
+	var active bool
+	if err := s.db.QueryRowContext(ctx, query, sessionID).Scan(&active); err != nil {
+		return false, fmt.Errorf("SessionActive: %w", err)
+	}
+	return active, nil
+}
+
+func (s *s) UserSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	const query = `
+		SELECT t.family_id, t.user_agent, t.client_ip, t.device_name, f.created_at, t.last_used_at, t.expires_at
+		FROM refresh_tokens t
+		JOIN (
+			SELECT family_id, MIN(created_at) AS created_at
+			FROM refresh_tokens
+			WHERE user_id = $1
+			GROUP BY family_id
+		) f ON f.family_id = t.family_id
+		WHERE t.user_id = $1 AND t.rotated_at IS NULL AND t.expires_at > now()
+		ORDER BY t.last_used_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UserSessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID, &session.UserAgent, &session.ClientIP, &session.DeviceName,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("UserSessions: %w", err)
 		}
-		return "", fmt.Errorf("RefreshToken: %w", err)
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserSessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (s *s) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	const query = `
+		INSERT INTO revoked_access_tokens (jti, expires_at, revoked_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, query, jti, expiresAt, time.Now().UTC()); err != nil {
+		return fmt.Errorf("RevokeAccessToken: %w", err)
+	}
+	return nil
+}
+
+// PurgeRevokedAccessTokens drops revocations of tokens that have expired and
+// are rejected anyway.
+func (s *s) PurgeRevokedAccessTokens(ctx context.Context) (int64, error) {
+	const query = `DELETE FROM revoked_access_tokens WHERE expires_at <= now()`
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeRevokedAccessTokens: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *s) AccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1 AND expires_at > now())`
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("AccessTokenRevoked: %w", err)
+	}
+	return revoked, nil
+}
+
+func (s *s) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const query = `INSERT INTO audit_events (user_id, event, metadata, created_at) VALUES ($1, $2, $3, $4)`
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return fmt.Errorf("SaveAuditEvent: %w", err)
 	}
+	if _, err := s.db.ExecContext(ctx, query, event.UserID, event.Event, metadata, time.Now().UTC()); err != nil {
+		return fmt.Errorf("SaveAuditEvent: %w", err)
+	}
+	return nil
+}
 
-	return userID, nil
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
 }
 
 func isUniqueViolation(err error) bool {
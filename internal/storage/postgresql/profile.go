@@ -0,0 +1,88 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *s) UpdateUserName(ctx context.Context, userID string, name string) (*models.UserModel, error) {
+	const query = `UPDATE users SET name = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING ` + userColumns
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, userID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("UpdateUserName: %w", err)
+	}
+	return user, nil
+}
+
+// SaveEmailChange replaces any pending email change of the user.
+func (s *s) SaveEmailChange(ctx context.Context, change models.EmailChange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SaveEmailChange: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_change_tokens WHERE user_id = $1`, change.UserID); err != nil {
+		return fmt.Errorf("SaveEmailChange: %w", err)
+	}
+
+	const query = `
+		INSERT INTO email_change_tokens (token_hash, user_id, new_email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query,
+		change.TokenHash, change.UserID, change.NewEmail, change.ExpiresAt, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("SaveEmailChange: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *s) ConsumeEmailChange(ctx context.Context, tokenHash []byte) (*models.EmailChange, error) {
+	const query = `
+		DELETE FROM email_change_tokens
+		WHERE token_hash = $1
+		RETURNING user_id, new_email, token_hash, expires_at`
+
+	var change models.EmailChange
+	err := s.db.QueryRowContext(ctx, query, tokenHash).
+		Scan(&change.UserID, &change.NewEmail, &change.TokenHash, &change.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("ConsumeEmailChange: %w", err)
+	}
+	if !change.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	return &change, nil
+}
+
+// UpdateUserEmail swaps the address of a user; the new address counts as
+// verified since the change was confirmed through it.
+func (s *s) UpdateUserEmail(ctx context.Context, userID string, email string) (*models.UserModel, error) {
+	const query = `UPDATE users SET email = $2, email_verified_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING ` + userColumns
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, userID, email))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, storage.ErrUserExists
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("UpdateUserEmail: %w", err)
+	}
+	return user, nil
+}
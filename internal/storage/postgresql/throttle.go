@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *s) LoginBlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	const query = `SELECT blocked_until FROM login_attempts WHERE key = $1`
+
+	var blockedUntil sql.NullTime
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&blockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("LoginBlockedUntil: %w", err)
+	}
+	return blockedUntil.Time, nil
+}
+
+// RecordLoginFailure increments the counter of the key, starting over when
+// the previous failure is older than window.
+func (s *s) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	const query = `
+		INSERT INTO login_attempts (key, failures, last_failed_at)
+		VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failed_at < now() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failed_at = now()
+		RETURNING failures`
+
+	var failures int
+	if err := s.db.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("RecordLoginFailure: %w", err)
+	}
+	return failures, nil
+}
+
+func (s *s) BlockLogin(ctx context.Context, key string, until time.Time) error {
+	const query = `UPDATE login_attempts SET blocked_until = $2 WHERE key = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, key, until); err != nil {
+		return fmt.Errorf("BlockLogin: %w", err)
+	}
+	return nil
+}
+
+func (s *s) PurgeLoginFailures(ctx context.Context, prefix string, window time.Duration) (int64, error) {
+	const query = `
+		DELETE FROM login_attempts
+		WHERE starts_with(key, $1)
+		AND last_failed_at < now() - make_interval(secs => $2)
+		AND (blocked_until IS NULL OR blocked_until <= now())`
+
+	res, err := s.db.ExecContext(ctx, query, prefix, window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("PurgeLoginFailures: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *s) ResetLoginFailures(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("ResetLoginFailures: %w", err)
+	}
+	return nil
+}
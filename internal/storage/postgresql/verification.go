@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"auth-api/internal/domain/models"
+	"auth-api/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveEmailVerification replaces any pending verification of the user, so
+// only the most recently sent link works.
+func (s *s) SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SaveEmailVerification: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = $1`, verification.UserID); err != nil {
+		return fmt.Errorf("SaveEmailVerification: %w", err)
+	}
+
+	const query = `
+		INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query,
+		verification.TokenHash, verification.UserID, verification.Email, verification.ExpiresAt, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("SaveEmailVerification: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeEmailVerification deletes the verification and returns it, so every
+// token can be used once.
+func (s *s) ConsumeEmailVerification(ctx context.Context, tokenHash []byte) (*models.EmailVerification, error) {
+	const query = `
+		DELETE FROM email_verification_tokens
+		WHERE token_hash = $1
+		RETURNING user_id, email, token_hash, expires_at`
+
+	var verification models.EmailVerification
+	err := s.db.QueryRowContext(ctx, query, tokenHash).
+		Scan(&verification.UserID, &verification.Email, &verification.TokenHash, &verification.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("ConsumeEmailVerification: %w", err)
+	}
+	if !verification.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	return &verification, nil
+}
+
+func (s *s) MarkEmailVerified(ctx context.Context, userID string, email string) error {
+	const query = `UPDATE users SET email_verified_at = now() WHERE id = $1 AND email = $2 AND deleted_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, email)
+	if err != nil {
+		return fmt.Errorf("MarkEmailVerified: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
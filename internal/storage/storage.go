@@ -8,4 +8,9 @@ var (
 	ErrTokenNotFound     = errors.New("refresh token not found or expired")
 	ErrTokenSaveFailed   = errors.New("failed to save refresh token")
 	ErrTokenRemoveFailed = errors.New("failed to remove refresh token")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrMFANotFound       = errors.New("mfa is not configured")
+	ErrMFACodeUsed       = errors.New("mfa code already used")
+	ErrPasskeyExists     = errors.New("passkey already registered")
+	ErrPasskeyNotFound   = errors.New("passkey not found")
 )
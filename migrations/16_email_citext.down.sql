@@ -0,0 +1,2 @@
+ALTER TABLE email_change_tokens ALTER COLUMN new_email TYPE TEXT;
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
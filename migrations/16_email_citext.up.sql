@@ -0,0 +1,27 @@
+-- Addresses differing only in case become equal under CITEXT. Such accounts
+-- cannot be merged automatically, so the migration stops and lists them.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(email, ', ') INTO duplicates
+    FROM (
+        SELECT lower(btrim(email)) AS email
+        FROM users
+        GROUP BY 1
+        HAVING count(*) > 1
+    ) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'accounts differing only in email case must be merged first: %', duplicates;
+    END IF;
+END $$;
+
+-- Bring existing rows to the form the application now stores. Non-ASCII
+-- domains keep their Unicode form, SQL has no punycode conversion.
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+UPDATE email_change_tokens SET new_email = lower(btrim(new_email)) WHERE new_email <> lower(btrim(new_email));
+
+CREATE EXTENSION IF NOT EXISTS citext;
+ALTER TABLE users ALTER COLUMN email TYPE CITEXT;
+ALTER TABLE email_change_tokens ALTER COLUMN new_email TYPE CITEXT;
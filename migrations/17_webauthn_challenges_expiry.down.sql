@@ -0,0 +1 @@
+DROP INDEX IF EXISTS webauthn_challenges_expires_at_idx;
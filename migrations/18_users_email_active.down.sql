@@ -0,0 +1,4 @@
+-- Fails while a deleted account and a live one share an address, purge the
+-- deleted ones first.
+DROP INDEX IF EXISTS users_email_active_idx;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
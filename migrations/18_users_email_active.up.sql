@@ -0,0 +1,3 @@
+-- Soft deleted accounts no longer hold on to their address during retention.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_active_idx ON users (email) WHERE deleted_at IS NULL;
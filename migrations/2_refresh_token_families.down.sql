@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS audit_events;
+
+DROP INDEX IF EXISTS refresh_tokens_user_id_idx;
+DROP INDEX IF EXISTS refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS rotated_at,
+    DROP COLUMN IF EXISTS parent_token_id,
+    DROP COLUMN IF EXISTS family_id;
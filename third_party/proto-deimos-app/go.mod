@@ -0,0 +1,8 @@
+module github.com/deeimos/proto-deimos-app
+
+go 1.24.0
+
+require (
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.5
+)